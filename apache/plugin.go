@@ -2,7 +2,14 @@ package apache
 
 import (
 	"github.com/turbot/tailpipe-plugin-apache/tables/access_log"
+	"github.com/turbot/tailpipe-plugin-apache/tables/config_directive"
+	"github.com/turbot/tailpipe-plugin-apache/tables/error_log"
+	"github.com/turbot/tailpipe-plugin-apache/tables/forensic_log"
+	"github.com/turbot/tailpipe-plugin-apache/tables/security_event"
+	"github.com/turbot/tailpipe-plugin-apache/tables/server_status"
+	"github.com/turbot/tailpipe-plugin-apache/tables/trafficserver_log"
 	"github.com/turbot/tailpipe-plugin-sdk/plugin"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
 	"github.com/turbot/tailpipe-plugin-sdk/table"
 )
 
@@ -12,10 +19,37 @@ func init() {
 	// Register the table, with type parameter:
 	// 1. table type
 	table.RegisterCustomTable[*access_log.AccessLogTable]()
+	table.RegisterCustomTable[*error_log.ErrorLogTable]()
+	table.RegisterCustomTable[*trafficserver_log.TrafficServerLogTable]()
+	table.RegisterCustomTable[*forensic_log.ForensicLogTable]()
+	table.RegisterCustomTable[*server_status.ServerStatusTable]()
+	table.RegisterCustomTable[*config_directive.ConfigDirectiveTable]()
+	table.RegisterCustomTable[*security_event.SecurityEventTable]()
 
 	// register formats
 	table.RegisterFormat[*access_log.AccessLogTableFormat]()
 	table.RegisterFormatPresets(access_log.AccessLogTableFormatPresets...)
+	table.RegisterFormat[*access_log.AccessLogJsonFormat]()
+	table.RegisterFormatPresets(access_log.AccessLogJsonFormatPresets...)
+	table.RegisterFormat[*access_log.AccessLogLogfmtFormat]()
+	table.RegisterFormatPresets(access_log.AccessLogLogfmtFormatPresets...)
+	table.RegisterFormat[*access_log.AccessLogTomcatFormat]()
+	table.RegisterFormatPresets(access_log.AccessLogTomcatFormatPresets...)
+	table.RegisterFormat[*error_log.ErrorLogTableFormat]()
+	table.RegisterFormatPresets(error_log.ErrorLogTableFormatPresets...)
+	table.RegisterFormat[*trafficserver_log.TrafficServerLogTableFormat]()
+	table.RegisterFormatPresets(trafficserver_log.TrafficServerLogTableFormatPresets...)
+	table.RegisterFormat[*forensic_log.ForensicLogTableFormat]()
+	table.RegisterFormatPresets(forensic_log.ForensicLogTableFormatPresets...)
+	table.RegisterFormat[*server_status.ServerStatusTableFormat]()
+	table.RegisterFormatPresets(server_status.ServerStatusTableFormatPresets...)
+	table.RegisterFormat[*config_directive.ConfigDirectiveTableFormat]()
+	table.RegisterFormatPresets(config_directive.ConfigDirectiveTableFormatPresets...)
+	table.RegisterFormat[*security_event.SecurityEventTableFormat]()
+	table.RegisterFormatPresets(security_event.SecurityEventTableFormatPresets...)
+
+	// register sources
+	row_source.RegisterRowSource[*server_status.ServerStatusSource]()
 }
 
 type Plugin struct {
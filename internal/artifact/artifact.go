@@ -0,0 +1,74 @@
+// Package artifact opens the artifacts read by the loaders of the plugin tables
+package artifact
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Open opens an artifact, decompressing it based on the file extension - gzip (.gz), zstd (.zst) and zip (.zip,
+// which must contain a single file) compressed artifacts are supported
+func Open(path string) (io.ReadCloser, error) {
+	switch filepath.Ext(path) {
+	case ".zip":
+		zipFile, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening %s: %w", path, err)
+		}
+		if len(zipFile.File) != 1 {
+			zipFile.Close()
+			return nil, fmt.Errorf("zip file %s must contain exactly one file", path)
+		}
+		rc, err := zipFile.File[0].Open()
+		if err != nil {
+			zipFile.Close()
+			return nil, fmt.Errorf("error opening file inside zip %s: %w", path, err)
+		}
+		return &multiCloser{Reader: rc, closers: []io.Closer{rc, zipFile}}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".gz":
+		gzReader, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error creating gzip reader for %s: %w", path, err)
+		}
+		return &multiCloser{Reader: gzReader, closers: []io.Closer{gzReader, f}}, nil
+	case ".zst":
+		zstReader, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error creating zstd reader for %s: %w", path, err)
+		}
+		rc := zstReader.IOReadCloser()
+		return &multiCloser{Reader: rc, closers: []io.Closer{rc, f}}, nil
+	}
+	return f, nil
+}
+
+// multiCloser is a ReadCloser which closes several underlying readers (e.g. a decompressor and the file it reads)
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var errs []error
+	for _, c := range m.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package artifact
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func Test_Open(t *testing.T) {
+	const content = "first\nsecond\n"
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		write   func(w io.Writer) (io.WriteCloser, error)
+		zipped  bool
+		wantErr bool
+	}{
+		{name: "access.log"},
+		{name: "access.log.gz", write: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }},
+		{name: "access.log.zst", write: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }},
+		{name: "access.log.zip", zipped: true},
+		{name: "missing.log.gz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if !tt.wantErr {
+				writeArtifact(t, path, content, tt.write, tt.zipped)
+			}
+
+			reader, err := Open(path)
+			if tt.wantErr {
+				if err == nil {
+					reader.Close()
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := reader.Close(); err != nil {
+				t.Errorf("unexpected close error: %v", err)
+			}
+			if string(got) != content {
+				t.Errorf("got %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+// writeArtifact writes the content to path, compressed by the writer returned by compress, or in a zip file
+func writeArtifact(t *testing.T, path, content string, compress func(io.Writer) (io.WriteCloser, error), zipped bool) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	switch {
+	case zipped:
+		zw := zip.NewWriter(f)
+		defer zw.Close()
+		if w, err = zw.Create("access.log"); err != nil {
+			t.Fatal(err)
+		}
+	case compress != nil:
+		cw, err := compress(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cw.Close()
+		w = cw
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+}
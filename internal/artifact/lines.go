@@ -0,0 +1,86 @@
+package artifact
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+)
+
+// MaxLineLength is the maximum length of a line read by ReadLines - longer lines are truncated
+// (the SDK row loaders stop reading an artifact at the first line longer than 64KiB)
+const MaxLineLength = 1024 * 1024
+
+// Line is a line read from an artifact
+type Line struct {
+	Text string
+	// the 1-based line number within the artifact
+	Number int64
+	// the byte offset of the start of the line within the (decompressed) artifact
+	Offset int64
+	// true if the line was longer than MaxLineLength, so Text only includes the start of the line
+	Truncated bool
+}
+
+// ReadLines reads the lines from the reader, calling onLine for each one
+// line endings ('\n' or '\r\n') are removed, but are included when calculating the offset of the next line
+func ReadLines(ctx context.Context, r io.Reader, onLine func(Line)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var number, offset int64
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var line []byte
+		var size int64
+		var err error
+		for {
+			var chunk []byte
+			chunk, err = reader.ReadSlice('\n')
+			size += int64(len(chunk))
+			// up to 2 bytes more than the maximum are kept, so a line ending can be removed from a line of the
+			// maximum length without treating it as truncated
+			if remaining := MaxLineLength + 2 - len(line); remaining > 0 {
+				line = append(line, chunk[:min(len(chunk), remaining)]...)
+			}
+			// ErrBufferFull means the line is longer than the buffer - keep reading until the end of the line
+			if !errors.Is(err, bufio.ErrBufferFull) {
+				break
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if size == 0 {
+			// end of the artifact
+			return nil
+		}
+
+		number++
+		text := trimLineEnding(line)
+		truncated := len(text) > MaxLineLength
+		if truncated {
+			text = text[:MaxLineLength]
+		}
+		onLine(Line{Text: string(text), Number: number, Offset: offset, Truncated: truncated})
+		offset += size
+
+		if err != nil {
+			// EOF
+			return nil
+		}
+	}
+}
+
+// trimLineEnding removes a trailing '\n' or '\r\n'
+func trimLineEnding(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+	}
+	return line
+}
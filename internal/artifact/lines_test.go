@@ -0,0 +1,75 @@
+package artifact
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ReadLines(t *testing.T) {
+	longLine := strings.Repeat("a", MaxLineLength+100)
+	maxLine := strings.Repeat("b", MaxLineLength)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Line
+	}{
+		{
+			name:  "lf line endings",
+			input: "first\nsecond\n",
+			want: []Line{
+				{Text: "first", Number: 1, Offset: 0},
+				{Text: "second", Number: 2, Offset: 6},
+			},
+		},
+		{
+			name:  "crlf line endings and no final newline",
+			input: "first\r\n\r\nthird",
+			want: []Line{
+				{Text: "first", Number: 1, Offset: 0},
+				{Text: "", Number: 2, Offset: 7},
+				{Text: "third", Number: 3, Offset: 9},
+			},
+		},
+		{
+			name:  "lines longer than the maximum are truncated",
+			input: longLine + "\nnext\n",
+			want: []Line{
+				{Text: longLine[:MaxLineLength], Number: 1, Offset: 0, Truncated: true},
+				{Text: "next", Number: 2, Offset: int64(len(longLine) + 1)},
+			},
+		},
+		{
+			name:  "lines of the maximum length are not truncated",
+			input: maxLine + "\r\nnext",
+			want: []Line{
+				{Text: maxLine, Number: 1, Offset: 0},
+				{Text: "next", Number: 2, Offset: int64(len(maxLine) + 2)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Line
+			err := ReadLines(context.Background(), strings.NewReader(tt.input), func(line Line) {
+				got = append(got, line)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadLines() = %d lines, want %d", len(got), len(tt.want))
+				for i := range min(len(got), len(tt.want)) {
+					if !reflect.DeepEqual(got[i], tt.want[i]) {
+						t.Errorf("line %d: got %d bytes (number %d, offset %d, truncated %v), want %d bytes (number %d, offset %d, truncated %v)",
+							i, len(got[i].Text), got[i].Number, got[i].Offset, got[i].Truncated,
+							len(tt.want[i].Text), tt.want[i].Number, tt.want[i].Offset, tt.want[i].Truncated)
+					}
+				}
+			}
+		})
+	}
+}
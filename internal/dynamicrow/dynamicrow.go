@@ -0,0 +1,16 @@
+// Package dynamicrow reads the source values of the rows mapped by the plugin tables
+package dynamicrow
+
+import "github.com/turbot/tailpipe-plugin-sdk/types"
+
+// NilValue is the value Apache logs for a field which is not set
+const NilValue = "-"
+
+// SourceValue returns the value of a source field, treating empty and nil ('-') values as missing
+func SourceValue(row *types.DynamicRow, field string) (string, bool) {
+	value, ok := row.GetSourceValue(field)
+	if !ok || value == "" || value == NilValue {
+		return "", false
+	}
+	return value, true
+}
@@ -0,0 +1,71 @@
+// Package tabletest contains the helpers shared by the tests of the plugin tables
+package tabletest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// Table is a table which maps and enriches rows using a format
+type Table interface {
+	Initialize(format formats.Format, customTableSchema *schema.TableSchema) error
+	GetTableDefinition() *schema.TableSchema
+	EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error)
+}
+
+// MapAndEnrich maps the data with the mapper of the format, then enriches the row with the table initialised with
+// the format - any mapping or enrichment error is returned
+func MapAndEnrich(t *testing.T, table Table, format formats.Format, data any) (*types.DynamicRow, error) {
+	t.Helper()
+
+	mapper, err := format.GetMapper()
+	if err != nil {
+		t.Fatalf("failed to get mapper: %v", err)
+	}
+	row, err := mapper.Map(context.Background(), data)
+	if err != nil {
+		return nil, err
+	}
+	return Enrich(t, table, format, row)
+}
+
+// Enrich enriches the row with the table initialised with the format
+func Enrich(t *testing.T, table Table, format formats.Format, row *types.DynamicRow) (*types.DynamicRow, error) {
+	t.Helper()
+
+	if err := table.Initialize(format, table.GetTableDefinition()); err != nil {
+		t.Fatalf("failed to initialise table: %v", err)
+	}
+	return table.EnrichRow(row, schema.SourceEnrichment{})
+}
+
+// AssertColumns checks the output columns of the row - a nil value means the column must not be set, and times
+// are compared as instants, as the offset depends on the time zone of the log
+func AssertColumns(t *testing.T, row *types.DynamicRow, want map[string]any) {
+	t.Helper()
+
+	for k, want := range want {
+		got := row.OutputColumns[k]
+		if want == nil {
+			if got != nil {
+				t.Errorf("%s: got %v, want nil", k, got)
+			}
+			continue
+		}
+		if wantTime, ok := want.(time.Time); ok {
+			if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(wantTime) {
+				t.Errorf("%s: got %v (%T), want %v", k, got, got, wantTime)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v (%T), want %v (%T)", k, got, got, want, want)
+		}
+	}
+}
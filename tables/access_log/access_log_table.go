@@ -1,11 +1,22 @@
 package access_log
 
 import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-apache/internal/dynamicrow"
 	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
 	"github.com/turbot/tailpipe-plugin-sdk/constants"
 	"github.com/turbot/tailpipe-plugin-sdk/error_types"
 	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
 	"github.com/turbot/tailpipe-plugin-sdk/row_source"
 	"github.com/turbot/tailpipe-plugin-sdk/schema"
 	"github.com/turbot/tailpipe-plugin-sdk/table"
@@ -18,12 +29,58 @@ const AccessLogTableNilValue = "-"
 // AccessLogTable - table for apache access logs
 type AccessLogTable struct {
 	table.CustomTableImpl
+
+	// redactor built from the format redaction options (nil if no redaction is configured)
+	redactor *redactor
+	// trie of the threat intel list addresses (nil if no lists are configured)
+	threatIntel *cidrTrie
+	// visitor session state (nil if sessionisation is not configured)
+	sessions *sessionTracker
+	// the last valid timestamp of each artifact, used for rows with an invalid timestamp in lenient parse mode
+	timestampFallback *timestampFallback
+	// the layout regex of the mapper, used to find the fields in the raw line (nil for keyed formats)
+	rawLineRegex *regexp.Regexp
 }
 
 func (c *AccessLogTable) Identifier() string {
 	return AccessLogTableIdentifier
 }
 
+// Initialize sets the format and schema for the table and builds any format specific enrichment state
+func (c *AccessLogTable) Initialize(format formats.Format, customTableSchema *schema.TableSchema) error {
+	if err := c.CustomTableImpl.Initialize(format, customTableSchema); err != nil {
+		return err
+	}
+
+	redactor, err := newRedactor(c.accessLogOptions())
+	if err != nil {
+		return err
+	}
+	c.redactor = redactor
+
+	threatIntel, err := loadThreatIntel(c.accessLogOptions())
+	if err != nil {
+		return err
+	}
+	c.threatIntel = threatIntel
+
+	sessions, err := newSessionTracker(c.accessLogOptions())
+	if err != nil {
+		return err
+	}
+	c.sessions = sessions
+	c.timestampFallback = newTimestampFallback()
+
+	mapper, err := c.getMapper()
+	if err != nil {
+		return err
+	}
+	if m, ok := mapper.(*accessLogMapper); ok {
+		c.rawLineRegex = m.re
+	}
+	return nil
+}
+
 func (c *AccessLogTable) GetDefaultFormat() formats.Format {
 	return DefaultApacheAccessLogFormat
 }
@@ -72,11 +129,56 @@ func (c *AccessLogTable) GetTableDefinition() *schema.TableSchema {
 				Description: "Protocol and version used in the request (e.g., 'HTTP/1.1')",
 				Type:        "varchar",
 			},
+			{
+				ColumnName:  "request_line",
+				Description: "Original request line, as logged",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_target_form",
+				Description: "Form of the request target (origin, absolute, authority or asterisk)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_host",
+				Description: "Host from an absolute-form request target or CONNECT authority-form target",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "is_malformed_request",
+				Description: "True if the request line could not be parsed as an HTTP request (e.g. a TLS handshake sent to a plain HTTP port)",
+				Type:        "boolean",
+			},
 			{
 				ColumnName:  "status",
 				Description: "HTTP response status code",
 				Type:        "integer",
 			},
+			{
+				ColumnName:  "status_class",
+				Description: "Class of the response status code (1xx, 2xx, 3xx, 4xx or 5xx)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "status_text",
+				Description: "Reason phrase for the response status code (e.g. 'Not Found'), or 'Aborted' for status 0",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "is_error",
+				Description: "True if the response status code is a client or server error (4xx or 5xx)",
+				Type:        "boolean",
+			},
+			{
+				ColumnName:  "is_client_error",
+				Description: "True if the response status code is a client error (4xx)",
+				Type:        "boolean",
+			},
+			{
+				ColumnName:  "is_server_error",
+				Description: "True if the response status code is a server error (5xx)",
+				Type:        "boolean",
+			},
 			{
 				ColumnName:  "body_bytes_sent",
 				Description: "Number of bytes sent to the client, excluding headers",
@@ -188,14 +290,153 @@ func (c *AccessLogTable) GetTableDefinition() *schema.TableSchema {
 				Description: "Total number of bytes transferred (sent + received)",
 				Type:        "integer",
 			},
+			{
+				ColumnName:  "http_host",
+				Description: "Value of the 'Host' request header",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "log_id",
+				Description: "Request log ID, as written to the error log for the same request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "unique_id",
+				Description: "Unique request identifier set by mod_unique_id",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "threat_categories",
+				Description: "Categories of the threat rules matched by the request (e.g. sql_injection, xss, path_traversal)",
+				Type:        "varchar[]",
+			},
+			{
+				ColumnName:  "threat_rule_ids",
+				Description: "IDs of the threat rules matched by the request",
+				Type:        "varchar[]",
+			},
+			{
+				ColumnName:  "threat_rules_version",
+				Description: "Version of the bundled threat rule set the request was evaluated against",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_x_forwarded_for",
+				Description: "Value of the 'X-Forwarded-For' request header",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "threat_intel_matches",
+				Description: "Names of the threat intel lists containing the client address or a forwarded client address",
+				Type:        "varchar[]",
+			},
+			{
+				ColumnName:  "session_id",
+				Description: "Identifier of the visitor session the request belongs to (if sessionisation is configured)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "raw_line",
+				Description: "Original log line, with any anonymization and redaction applied (only set if include_raw_line is enabled for the format)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "parse_error",
+				Description: "Details of any fields which could not be parsed",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "is_unparsed",
+				Description: "True if the line did not match the layout, in which case only raw_line, parse_error and the provenance columns are set (only set if keep_unparsed_lines is enabled for the format)",
+				Type:        "boolean",
+			},
+			{
+				ColumnName:  "log_file",
+				Description: "Path of the log file the row was read from",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "log_line",
+				Description: "Line number of the row within the log file",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "log_offset",
+				Description: "Byte offset of the start of the line within the log file (after decompression, for compressed files)",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "is_redacted",
+				Description: "True if any part of the request URI, query string or referer was redacted",
+				Type:        "boolean",
+			},
+			{
+				ColumnName:  "peer_addr",
+				Description: "Address (or hostname) of the peer of the underlying connection, which may differ from the client address when mod_remoteip is used",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "connection_log_id",
+				Description: "Connection log ID, as written to the error log for the same connection",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_headers",
+				Description: "Logged request headers without a dedicated column, keyed by header name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "response_headers",
+				Description: "Logged response headers, keyed by header name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "request_trailers",
+				Description: "Logged request trailers, keyed by trailer name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "response_trailers",
+				Description: "Logged response trailers, keyed by trailer name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "cookies",
+				Description: "Logged request cookies, keyed by cookie name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "environment_variables",
+				Description: "Logged environment variables without a dedicated column, keyed by variable name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "notes",
+				Description: "Logged notes set by other modules, keyed by note name",
+				Type:        "json",
+			},
+			{
+				ColumnName:  "servlet_session_id",
+				Description: "Servlet session ID of the request (logged by the Tomcat %S pattern code)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "thread_name",
+				Description: "Name of the thread which processed the request (logged by the Tomcat %I pattern code)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "commit_time_ms",
+				Description: "Time taken to commit the response in milliseconds (logged by the Tomcat %F pattern code)",
+				Type:        "bigint",
+			},
 		},
 		NullIf: "-", // default null value
 	}
 }
 
 func (c *AccessLogTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
-	// ask our CustomTableImpl for the mapper
-	mapper, err := c.Format.GetMapper()
+	mapper, err := c.getMapper()
 	if err != nil {
 		return nil, err
 	}
@@ -206,26 +447,165 @@ func (c *AccessLogTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.Dyn
 			// any artifact source
 			SourceName: constants.ArtifactSourceIdentifier,
 			Mapper:     mapper,
-			Options: []row_source.RowSourceOption{
-				artifact_source.WithRowPerLine(),
-			},
+			Options:    c.getSourceOptions(mapper),
 		},
 	}, nil
 }
 
+// getSourceOptions returns the artifact source options for the mapper
+// the access log mappers are passed lines read by the access log loader, which include the line number
+// of each line - any other mapper expects the lines as plain strings
+func (c *AccessLogTable) getSourceOptions(mapper mappers.Mapper[*types.DynamicRow]) []row_source.RowSourceOption {
+	switch mapper.(type) {
+	case *accessLogMapper, *accessLogKeyValueMapper:
+		return []row_source.RowSourceOption{
+			artifact_source.WithArtifactLoader(newAccessLogLoader()),
+		}
+	}
+	return []row_source.RowSourceOption{
+		artifact_source.WithRowPerLine(),
+	}
+}
+
+// getMapper returns the mapper for the table format
+// regex formats (including the default format) use the access log mapper, so the request line is parsed in the same
+// way as for the apache_access_log format - for any other format we ask our CustomTableImpl for the mapper
+func (c *AccessLogTable) getMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	if format, ok := c.Format.(*formats.Regex); ok {
+		regex, err := format.GetRegex()
+		if err != nil {
+			return nil, err
+		}
+		mapper, err := newAccessLogMapper(regex)
+		if err != nil {
+			return nil, err
+		}
+		return mapper, nil
+	}
+
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+	// in lenient mode the mapper validates field types against the table schema
+	if c.accessLogOptions().isLenient() {
+		switch m := mapper.(type) {
+		case *accessLogMapper:
+			m.fieldTypes = getFieldTypes(c.Schema)
+		case *accessLogKeyValueMapper:
+			m.fieldTypes = getFieldTypes(c.Schema)
+		}
+	}
+	return mapper, nil
+}
+
 func (c *AccessLogTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	format := c.accessLogOptions()
+	sourceLocation := sourceEnrichmentFields.ResolveSourceLocation()
+
 	if ts, ok := row.GetSourceValue("timestamp"); ok && ts != AccessLogTableNilValue {
 		t, err := helpers.ParseTime(ts)
 		if err != nil {
 			return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
 		}
 		row.OutputColumns[constants.TpTimestamp] = t
+		if format.isLenient() || format.keepsUnparsedLines() {
+			c.timestampFallback.update(sourceLocation, t)
+		}
+	} else if format.usesCollectionTime() {
+		// the layout does not log the time
+		row.OutputColumns[constants.TpTimestamp] = time.Now()
+	} else if format.isLenient() || format.keepsUnparsedLines() {
+		// the timestamp was missing or invalid (and nulled by the mapper), or the line could not be parsed
+		row.OutputColumns[constants.TpTimestamp] = c.timestampFallback.get(sourceLocation)
+	}
+
+	// provenance
+	if sourceLocation != "" {
+		row.OutputColumns["log_file"] = sourceLocation
+	}
+
+	// lines which did not match the layout have no fields to enrich
+	if unparsed, _ := row.GetSourceValue("is_unparsed"); unparsed == "true" {
+		c.enrichUnparsed(format, row)
+		return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+	}
+
+	// assign the request to a visitor session (this uses the original client address, so must be done before anonymization)
+	c.enrichSession(row, sourceEnrichmentFields)
+
+	// match the client addresses against the threat intel lists (this uses the original values, so must be done before anonymization)
+	c.enrichThreatIntel(row)
+
+	// anonymize the client address and username before they are used to populate any columns
+	remoteAddr, hasRemoteAddr := dynamicrow.SourceValue(row, "remote_addr")
+	if hasRemoteAddr && format.anonymizesIps() {
+		remoteAddr = format.anonymizeIp(remoteAddr)
+		row.OutputColumns["remote_addr"] = remoteAddr
+		row.OutputColumns[constants.TpSourceIP] = remoteAddr
+	}
+	peerAddr, hasPeerAddr := dynamicrow.SourceValue(row, "peer_addr")
+	if hasPeerAddr && format.anonymizesIps() {
+		peerAddr = format.anonymizeIp(peerAddr)
+		row.OutputColumns["peer_addr"] = peerAddr
+	}
+	remoteUser, hasRemoteUser := dynamicrow.SourceValue(row, "remote_user")
+	if hasRemoteUser && format.anonymizesUsernames() {
+		remoteUser = format.anonymizeUsername(remoteUser)
+		row.OutputColumns["remote_user"] = remoteUser
+	}
+	forwardedAddrs := getForwardedAddrs(row)
+	if len(forwardedAddrs) > 0 && format.anonymizesIps() {
+		for i, addr := range forwardedAddrs {
+			forwardedAddrs[i] = format.anonymizeIp(addr)
+		}
+		row.OutputColumns["http_x_forwarded_for"] = strings.Join(forwardedAddrs, ", ")
+	}
+
+	// status derived columns
+	enrichStatus(row)
+
+	// evaluate the threat rules (this uses the original values, so must be done before redaction)
+	c.enrichThreats(row)
+
+	// apply redaction rules
+	if c.redactor != nil {
+		redacted := false
+		for _, field := range redactedFields {
+			if value, ok := dynamicrow.SourceValue(row, field); ok {
+				if value, ok = c.redactor.redact(value); ok {
+					row.OutputColumns[field] = value
+					redacted = true
+				}
+			}
+		}
+		// the request line is redacted using the redacted request URI, so the method and protocol are retained
+		if line, ok := dynamicrow.SourceValue(row, "request_line"); ok {
+			uri, _ := dynamicrow.SourceValue(row, "request_uri")
+			redactedUri := uri
+			if value, ok := row.OutputColumns["request_uri"].(string); ok {
+				redactedUri = value
+			}
+			if line, ok = c.redactor.redactRequestLine(line, uri, redactedUri); ok {
+				row.OutputColumns["request_line"] = line
+				redacted = true
+			}
+		}
+		row.OutputColumns["is_redacted"] = redacted
 	}
 
 	// tp_ips
 	var ips []string
-	if ip, ok := row.GetSourceValue("remote_addr"); ok && ip != AccessLogTableNilValue {
-		ips = append(ips, ip)
+	if hasRemoteAddr {
+		ips = append(ips, remoteAddr)
+	}
+	if hasPeerAddr && !slices.Contains(ips, peerAddr) {
+		ips = append(ips, peerAddr)
+	}
+	for _, addr := range forwardedAddrs {
+		if !slices.Contains(ips, addr) {
+			ips = append(ips, addr)
+		}
 	}
 	if ip, ok := row.GetSourceValue("local_addr"); ok && ip != AccessLogTableNilValue {
 		ips = append(ips, ip)
@@ -235,10 +615,428 @@ func (c *AccessLogTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields
 	}
 
 	// tp_usernames
-	if username, ok := row.GetSourceValue("remote_user"); ok && username != AccessLogTableNilValue {
-		row.OutputColumns[constants.TpUsernames] = []string{username}
+	if hasRemoteUser {
+		row.OutputColumns[constants.TpUsernames] = []string{remoteUser}
+	}
+
+	// tp_destination_ip
+	if ip, ok := row.GetSourceValue("local_addr"); ok && ip != AccessLogTableNilValue {
+		row.OutputColumns[constants.TpDestinationIP] = ip
+	}
+
+	// tp_domains
+	var domains []string
+	if serverName, ok := row.GetSourceValue("server_name"); ok {
+		domains = appendDomain(domains, serverName)
+	}
+	if host, ok := row.GetSourceValue("http_host"); ok {
+		domains = appendDomain(domains, host)
+	}
+	if referer, ok := row.GetSourceValue("http_referer"); ok && referer != AccessLogTableNilValue {
+		if u, err := url.Parse(referer); err == nil {
+			domains = appendDomain(domains, u.Host)
+		}
+	}
+	if len(domains) > 0 {
+		row.OutputColumns[constants.TpDomains] = domains
+	}
+
+	// tp_akas
+	var akas []string
+	for _, field := range []string{"log_id", "connection_log_id", "unique_id"} {
+		if id, ok := row.GetSourceValue(field); ok && id != "" && id != AccessLogTableNilValue {
+			akas = append(akas, id)
+		}
+	}
+	if len(akas) > 0 {
+		row.OutputColumns[constants.TpAkas] = akas
+	}
+
+	// named values (e.g. request headers) are collected into json objects by the mapper
+	enrichNamedValues(row)
+
+	// tp_index
+	if index := getIndex(format, row); index != "" {
+		row.OutputColumns[constants.TpIndex] = index
+	}
+
+	// remove any anonymized or redacted values from the raw line
+	if rawLine, ok := dynamicrow.SourceValue(row, "raw_line"); ok {
+		row.OutputColumns["raw_line"] = c.sanitiseRawLine(format, row, rawLine)
 	}
 
 	// now call the base class to do the rest of the enrichment
 	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
 }
+
+// enrichThreats evaluates the bundled threat rules against the request and populates the threat columns
+func (c *AccessLogTable) enrichThreats(row *types.DynamicRow) {
+	var inputs threatInputs
+	if uri, ok := dynamicrow.SourceValue(row, "request_uri"); ok {
+		inputs.uri = uri
+	}
+	if query, ok := dynamicrow.SourceValue(row, "query_string"); ok && !strings.Contains(inputs.uri, "?") {
+		inputs.uri += query
+	}
+	inputs.uri = normaliseThreatInput(inputs.uri)
+	if userAgent, ok := dynamicrow.SourceValue(row, "http_user_agent"); ok {
+		inputs.userAgent = strings.ToLower(userAgent)
+	}
+	if referer, ok := dynamicrow.SourceValue(row, "http_referer"); ok {
+		inputs.referer = normaliseThreatInput(referer)
+	}
+
+	row.OutputColumns["threat_rules_version"] = ThreatRulesVersion
+	categories, ruleIds := matchThreatRules(inputs)
+	if len(ruleIds) > 0 {
+		row.OutputColumns["threat_categories"] = categories
+		row.OutputColumns["threat_rule_ids"] = ruleIds
+	}
+}
+
+// enrichSession populates session_id, identifying visitors by the session cookie if configured and present,
+// otherwise by client address and user agent
+func (c *AccessLogTable) enrichSession(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) {
+	if c.sessions == nil {
+		return
+	}
+	timestamp, ok := row.OutputColumns[constants.TpTimestamp].(time.Time)
+	if !ok {
+		return
+	}
+
+	var visitor string
+	if cookie := c.sessions.format.SessionCookie; cookie != nil {
+		if value, ok := dynamicrow.SourceValue(row, cookieGroupName(*cookie)); ok {
+			visitor = "cookie:" + value
+		}
+	}
+	if visitor == "" {
+		remoteAddr, _ := dynamicrow.SourceValue(row, "remote_addr")
+		userAgent, _ := dynamicrow.SourceValue(row, "http_user_agent")
+		if remoteAddr == "" && userAgent == "" {
+			return
+		}
+		// without an anonymization key the session id is an unkeyed hash, so truncated addresses identify the visitor
+		// to prevent the address being recovered from it
+		if format := c.sessions.format; format.anonymizesIps() && !format.hasAnonymizationKey() {
+			remoteAddr = format.anonymizeIp(remoteAddr)
+		}
+		visitor = "client:" + remoteAddr + "\x00" + userAgent
+	}
+
+	row.OutputColumns["session_id"] = c.sessions.sessionId(sourceEnrichmentFields.ResolveSourceLocation(), visitor, timestamp)
+}
+
+// enrichThreatIntel populates threat_intel_matches with the names of the threat intel lists containing
+// the client address or any of the forwarded client addresses
+func (c *AccessLogTable) enrichThreatIntel(row *types.DynamicRow) {
+	if c.threatIntel == nil {
+		return
+	}
+
+	var addrs []string
+	if remoteAddr, ok := dynamicrow.SourceValue(row, "remote_addr"); ok {
+		addrs = append(addrs, remoteAddr)
+	}
+	addrs = append(addrs, getForwardedAddrs(row)...)
+
+	var matches []string
+	for _, a := range addrs {
+		if addr, err := netip.ParseAddr(a); err == nil {
+			matches = c.threatIntel.lookup(addr, matches)
+		}
+	}
+	if len(matches) > 0 {
+		row.OutputColumns["threat_intel_matches"] = matches
+	}
+}
+
+// accessLogOptions returns the options of the table format, if it is an access log format
+// otherwise (e.g. for a regex format) empty options are returned, meaning all options take their defaults
+func (c *AccessLogTable) accessLogOptions() *AccessLogOptions {
+	if format, ok := c.Format.(interface{ getAccessLogOptions() *AccessLogOptions }); ok {
+		return format.getAccessLogOptions()
+	}
+	return &AccessLogOptions{}
+}
+
+// enrichUnparsed removes any sensitive values from the raw line of a row which did not match the layout
+// as the fields of the line are unknown, any IP addresses in the line are anonymized and the redaction
+// patterns and detectors are applied to the whole line
+func (c *AccessLogTable) enrichUnparsed(format *AccessLogOptions, row *types.DynamicRow) {
+	rawLine, ok := dynamicrow.SourceValue(row, "raw_line")
+	if !ok {
+		return
+	}
+	value := format.anonymizeIpsInText(rawLine)
+	if c.redactor != nil {
+		value, _ = c.redactor.redactContent(value, value)
+	}
+	if value != rawLine {
+		row.OutputColumns["raw_line"] = value
+	}
+}
+
+// rawLineFields are the fields which may be changed by anonymization or redaction, so must be sanitised in the raw line
+var rawLineFields = append([]string{"remote_addr", "peer_addr", "remote_user", "request_line", "http_x_forwarded_for"}, redactedFields...)
+
+// sanitiseRawLine replaces the original values of any fields changed by anonymization or redaction in the raw line,
+// so the raw line does not expose values which have been removed from their columns
+//
+// Values are only replaced where the field appears in the line, so a value which is also part of other text (e.g. a
+// short username, or an address contained in another address) does not change that text. For layouts the line is
+// rebuilt from the offsets of the fields matched by the layout regex, and for keyed formats (e.g. JSON) only whole
+// values are replaced.
+func (c *AccessLogTable) sanitiseRawLine(format *AccessLogOptions, row *types.DynamicRow, rawLine string) string {
+	if c.rawLineRegex != nil {
+		if match := c.rawLineRegex.FindStringSubmatchIndex(rawLine); match != nil {
+			return c.sanitiseRawLineFields(format, row, rawLine, match)
+		}
+	}
+	return sanitiseRawLineValues(row, rawLine)
+}
+
+// sanitiseRawLineFields rebuilds the raw line, replacing the fields matched by the layout regex
+func (c *AccessLogTable) sanitiseRawLineFields(format *AccessLogOptions, row *types.DynamicRow, rawLine string, match []int) string {
+	type span struct {
+		start, end int
+		value      string
+	}
+	var spans []span
+	for i, name := range c.rawLineRegex.SubexpNames() {
+		start, end := match[2*i], match[2*i+1]
+		if start < 0 || !slices.Contains(rawLineFields, name) {
+			continue
+		}
+		if value := c.sanitiseRawLineField(format, row, name, rawLine[start:end]); value != rawLine[start:end] {
+			spans = append(spans, span{start, end, value})
+		}
+	}
+	if len(spans) == 0 {
+		return rawLine
+	}
+
+	// fields may be nested (e.g. the request URI in the request line), in which case the outer field is replaced
+	slices.SortStableFunc(spans, func(a, b span) int { return a.start - b.start })
+	var sb strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.start < pos {
+			continue
+		}
+		sb.WriteString(rawLine[pos:s.start])
+		sb.WriteString(s.value)
+		pos = s.end
+	}
+	sb.WriteString(rawLine[pos:])
+	return sb.String()
+}
+
+// sanitiseRawLineField returns the sanitised value of a field matched in the raw line
+func (c *AccessLogTable) sanitiseRawLineField(format *AccessLogOptions, row *types.DynamicRow, field, value string) string {
+	if value == "" || value == AccessLogTableNilValue {
+		return value
+	}
+	switch field {
+	case "http_x_forwarded_for":
+		// the addresses are replaced individually, so the formatting of the header is retained
+		return anonymizeForwardedFor(format, value)
+	}
+
+	// the value of the column, if the field is matched once (or the value is the same for each match)
+	if original, ok := dynamicrow.SourceValue(row, field); ok && original == value {
+		if sanitised, ok := row.OutputColumns[field].(string); ok {
+			return sanitised
+		}
+		return value
+	}
+
+	// otherwise (e.g. the client address is logged by both %h and %a) the value is sanitised as its column would be
+	switch field {
+	case "remote_addr", "peer_addr":
+		return format.anonymizeIp(value)
+	case "remote_user":
+		return format.anonymizeUsername(value)
+	case "request_line":
+		if c.redactor != nil {
+			value, _ = c.redactor.redactContent(value, value)
+		}
+	default:
+		if c.redactor != nil {
+			value, _ = c.redactor.redact(value)
+		}
+	}
+	return value
+}
+
+// anonymizeForwardedFor anonymizes the addresses in an X-Forwarded-For header, retaining the separators and any ports
+func anonymizeForwardedFor(format *AccessLogOptions, value string) string {
+	if !format.anonymizesIps() {
+		return value
+	}
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		addr := strings.TrimSpace(part)
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			addr = h
+		}
+		addr = strings.Trim(addr, "[]")
+		if addr == "" || addr == AccessLogTableNilValue || strings.EqualFold(addr, "unknown") {
+			continue
+		}
+		parts[i] = strings.Replace(part, addr, format.anonymizeIp(addr), 1)
+	}
+	return strings.Join(parts, ",")
+}
+
+// sanitiseRawLineValues replaces the original values of the changed fields in a raw line with keyed values (e.g. a JSON
+// object), where each value is a whole token - a value is only replaced if it is enclosed in quotes, or follows an =
+// and ends at whitespace or the end of the line
+func sanitiseRawLineValues(row *types.DynamicRow, rawLine string) string {
+	for _, field := range rawLineFields {
+		original, ok := dynamicrow.SourceValue(row, field)
+		if !ok {
+			continue
+		}
+		value, ok := row.OutputColumns[field].(string)
+		if !ok || value == original {
+			continue
+		}
+		rawLine = replaceValueTokens(rawLine, original, value)
+		// values in a JSON line may be escaped
+		if escaped := jsonEscape(original); escaped != original {
+			rawLine = replaceValueTokens(rawLine, escaped, jsonEscape(value))
+		}
+	}
+	return rawLine
+}
+
+// replaceValueTokens replaces the occurrences of original in the line which are whole values
+func replaceValueTokens(line, original, value string) string {
+	var sb strings.Builder
+	pos := 0
+	for {
+		i := strings.Index(line[pos:], original)
+		if i < 0 {
+			break
+		}
+		start, end := pos+i, pos+i+len(original)
+		before := byte(0)
+		if start > 0 {
+			before = line[start-1]
+		}
+		after := byte(0)
+		if end < len(line) {
+			after = line[end]
+		}
+		quoted := before == '"' && after == '"'
+		unquoted := before == '=' && (after == 0 || after == ' ' || after == '\t')
+		if quoted || unquoted {
+			sb.WriteString(line[pos:start])
+			sb.WriteString(value)
+		} else {
+			sb.WriteString(line[pos:end])
+		}
+		pos = end
+	}
+	sb.WriteString(line[pos:])
+	return sb.String()
+}
+
+// jsonEscape returns a value as it is written in a JSON string, without the enclosing quotes
+func jsonEscape(value string) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return value
+	}
+	res := strings.TrimSuffix(sb.String(), "\n")
+	return res[1 : len(res)-1]
+}
+
+// enrichNamedValues converts the json columns collected by the mapper from named values (e.g. %{X-Request-Id}i)
+// to objects, so they are stored as json objects rather than strings
+func enrichNamedValues(row *types.DynamicRow) {
+	for _, column := range namedValueColumns {
+		value, ok := row.GetSourceValue(column)
+		if !ok {
+			continue
+		}
+		var values map[string]string
+		if err := json.Unmarshal([]byte(value), &values); err == nil {
+			row.OutputColumns[column] = values
+		}
+	}
+}
+
+// getForwardedAddrs returns the addresses in the X-Forwarded-For header, stripping any ports
+func getForwardedAddrs(row *types.DynamicRow) []string {
+	value, ok := dynamicrow.SourceValue(row, "http_x_forwarded_for")
+	if !ok {
+		return nil
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			addr = h
+		}
+		addr = strings.Trim(addr, "[]")
+		if addr != "" && addr != AccessLogTableNilValue && !strings.EqualFold(addr, "unknown") {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// getIndex returns the tp_index value for the row, derived from the field specified by the format's tp_index_field
+// (if no field is configured, or the field has no value, an empty string is returned and the default index is used)
+func getIndex(format *AccessLogOptions, row *types.DynamicRow) string {
+	if format.TpIndexField == nil {
+		return ""
+	}
+
+	value, ok := dynamicrow.SourceValue(row, *format.TpIndexField)
+	if !ok {
+		return ""
+	}
+	value = strings.ToLower(value)
+	// the Host header may include a port
+	if *format.TpIndexField == "http_host" {
+		if h, _, err := net.SplitHostPort(value); err == nil {
+			value = h
+		}
+	}
+
+	// the index is used as a hive partition path segment so restrict it to safe characters
+	index := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, value)
+	if strings.Trim(index, ".") == "" {
+		return ""
+	}
+	return index
+}
+
+// appendDomain adds the host part of value to domains, skipping empty values, IP addresses and duplicates
+func appendDomain(domains []string, value string) []string {
+	if value == "" || value == AccessLogTableNilValue {
+		return domains
+	}
+	host := strings.ToLower(value)
+	// strip any port (this also handles bracketed IPv6 literals)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || net.ParseIP(host) != nil || slices.Contains(domains, host) {
+		return domains
+	}
+	return append(domains, host)
+}
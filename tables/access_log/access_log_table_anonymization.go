@@ -0,0 +1,133 @@
+package access_log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+)
+
+const (
+	IpAnonymizationTruncate = "truncate"
+	IpAnonymizationHmac     = "hmac"
+
+	defaultIpv4PrefixLength = 24
+	defaultIpv6PrefixLength = 48
+)
+
+// validateAnonymization validates the anonymization options of the format
+func (a *AccessLogOptions) validateAnonymization() error {
+	if a.IpAnonymization != nil {
+		switch *a.IpAnonymization {
+		case IpAnonymizationTruncate, IpAnonymizationHmac:
+		default:
+			return fmt.Errorf("invalid ip_anonymization '%s': must be one of %s, %s", *a.IpAnonymization, IpAnonymizationTruncate, IpAnonymizationHmac)
+		}
+	}
+	// values are always hashed with the key, as an unkeyed hash of an address or username can be brute forced
+	// (truncation does not need a key - client hostnames, which cannot be truncated, are hashed if a key is set and
+	// redacted otherwise)
+	if (a.hashesIps() || a.anonymizesUsernames()) && !a.hasAnonymizationKey() {
+		return fmt.Errorf("anonymization_key must be set when ip_anonymization is '%s' or hash_usernames is set", IpAnonymizationHmac)
+	}
+	if a.Ipv4PrefixLength != nil && (*a.Ipv4PrefixLength < 0 || *a.Ipv4PrefixLength > 32) {
+		return fmt.Errorf("invalid ipv4_prefix_length %d: must be between 0 and 32", *a.Ipv4PrefixLength)
+	}
+	if a.Ipv6PrefixLength != nil && (*a.Ipv6PrefixLength < 0 || *a.Ipv6PrefixLength > 128) {
+		return fmt.Errorf("invalid ipv6_prefix_length %d: must be between 0 and 128", *a.Ipv6PrefixLength)
+	}
+	return nil
+}
+
+// anonymizesIps returns whether client IP addresses should be anonymized
+func (a *AccessLogOptions) anonymizesIps() bool {
+	return a.IpAnonymization != nil
+}
+
+// hashesIps returns whether client IP addresses should be hashed rather than truncated
+func (a *AccessLogOptions) hashesIps() bool {
+	return a.anonymizesIps() && *a.IpAnonymization == IpAnonymizationHmac
+}
+
+// hasAnonymizationKey returns whether an anonymization key is set
+func (a *AccessLogOptions) hasAnonymizationKey() bool {
+	return a.AnonymizationKey != nil && *a.AnonymizationKey != ""
+}
+
+// anonymizesUsernames returns whether usernames should be hashed
+func (a *AccessLogOptions) anonymizesUsernames() bool {
+	return a.HashUsernames != nil && *a.HashUsernames
+}
+
+// anonymizeIp truncates or hashes a client address, depending on the configured ip_anonymization mode
+// if truncation is requested for a value which is not an IP address (e.g. a hostname resolved by HostnameLookups)
+// the value is hashed instead, as it cannot be truncated, or redacted if no anonymization key is set
+func (a *AccessLogOptions) anonymizeIp(addr string) string {
+	if !a.anonymizesIps() {
+		return addr
+	}
+	if a.hashesIps() {
+		return a.hash(addr)
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		if !a.hasAnonymizationKey() {
+			return redactedValue
+		}
+		return a.hash(addr)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		prefix := defaultIpv4PrefixLength
+		if a.Ipv4PrefixLength != nil {
+			prefix = *a.Ipv4PrefixLength
+		}
+		return ip4.Mask(net.CIDRMask(prefix, 32)).String()
+	}
+
+	prefix := defaultIpv6PrefixLength
+	if a.Ipv6PrefixLength != nil {
+		prefix = *a.Ipv6PrefixLength
+	}
+	return ip.Mask(net.CIDRMask(prefix, 128)).String()
+}
+
+// ipInTextRegex matches candidate IPv4 and IPv6 addresses within free text (candidates are validated before replacement)
+var ipInTextRegex = regexp.MustCompile(`(?i)(?:\d{1,3}\.){3}\d{1,3}|[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}`)
+
+// anonymizeIpsInText anonymizes any IP addresses found in the text, if ip_anonymization is set
+func (a *AccessLogOptions) anonymizeIpsInText(text string) string {
+	if !a.anonymizesIps() {
+		return text
+	}
+	return ipInTextRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if addr, err := netip.ParseAddr(match); err != nil || addr.IsUnspecified() {
+			return match
+		}
+		return a.anonymizeIp(match)
+	})
+}
+
+// anonymizeUsername hashes a username, if hash_usernames is set
+func (a *AccessLogOptions) anonymizeUsername(username string) string {
+	if !a.anonymizesUsernames() {
+		return username
+	}
+	return a.hash(username)
+}
+
+// hash returns a hex encoded HMAC-SHA256 of the value using the anonymization key, truncated to 128 bits
+// the key is required by validateAnonymization whenever a value is hashed to anonymize it
+func (a *AccessLogOptions) hash(value string) string {
+	var key []byte
+	if a.AnonymizationKey != nil {
+		key = []byte(*a.AnonymizationKey)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,392 @@
+package access_log
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/go-kit/helpers"
+)
+
+// apacheDirectiveRegex matches a LogFormat directive - a '%' followed by any status code conditions (e.g. 400,501 or !200),
+// original/final request modifiers ('<' or '>') and {argument}, then the directive letter (or '^' and a two letter
+// directive, e.g. ^ti)
+var apacheDirectiveRegex = regexp.MustCompile(`%(?:%|((?:[!<>,0-9]|\{[^}]*\})*)(\^[a-zA-Z]{2}|[a-zA-Z]))`)
+
+// namedValueDirective describes a directive which logs a named value, e.g. %{X-Request-Id}i
+// values which do not have a dedicated column in apacheRegexMap are collected into a json column, keyed by name
+type namedValueDirective struct {
+	// the json column the values are collected into
+	column string
+	// the prefix of the regex group names used to capture the values
+	groupPrefix string
+}
+
+var namedValueDirectives = map[string]namedValueDirective{
+	"i":   {column: "request_headers", groupPrefix: "request_header__"},
+	"o":   {column: "response_headers", groupPrefix: "response_header__"},
+	"e":   {column: "environment_variables", groupPrefix: "environment_variable__"},
+	"n":   {column: "notes", groupPrefix: "note__"},
+	"C":   {column: "cookies"}, // cookie group names are returned by cookieGroupName
+	"^ti": {column: "request_trailers", groupPrefix: "request_trailer__"},
+	"^to": {column: "response_trailers", groupPrefix: "response_trailer__"},
+}
+
+// layoutSyntax is the directive table of a layout language - the mod_log_config LogFormat, or a language derived
+// from it (e.g. the Tomcat AccessLogValve pattern)
+type layoutSyntax struct {
+	// the regex of each directive with a dedicated column, keyed by directive
+	regexMap map[string]string
+	// the directives which log a named value, keyed by directive letter
+	namedValues map[string]namedValueDirective
+}
+
+var apacheLayoutSyntax = layoutSyntax{regexMap: apacheRegexMap, namedValues: namedValueDirectives}
+
+// namedValueColumns are the json columns populated from named value directives, in schema order
+var namedValueColumns = []string{"request_headers", "response_headers", "request_trailers", "response_trailers", "cookies", "environment_variables", "notes"}
+
+// namedValue is a named value captured by the layout
+type namedValue struct {
+	// the regex group name
+	group string
+	// the json column the value is collected into
+	column string
+	// the name of the header, variable, note, cookie or trailer
+	name string
+}
+
+// parsedLayout is a layout converted to a regex
+type parsedLayout struct {
+	syntax layoutSyntax
+	regex  string
+	// the named values captured by the layout which are collected into json columns
+	namedValues []namedValue
+	// the strftime format of each part of the timestamp, when it is logged by several %{format}t directives
+	// (e.g. [%{%d/%b/%Y:%H:%M:%S}t %{%z}t]) - the parts are captured by the timestamp, timestamp_2, ... groups
+	timestampFormats []string
+
+	// the epoch and fractional seconds timestamp groups already captured by the layout
+	groups map[string]bool
+}
+
+// parseLayout converts the layout to a regex
+//
+// The full mod_log_config directive table is supported. Status code conditions (e.g. %400,501{User-agent}i) are
+// accepted, but the condition is not evaluated - when it is not met Apache logs '-', which is mapped to null.
+// The '<' and '>' modifiers, which choose between the original and final request of an internal redirect,
+// are also accepted and map to the same column as the unmodified directive.
+func (a *AccessLogTableFormat) parseLayout() (*parsedLayout, error) {
+	return parseLayout(a.Layout, apacheLayoutSyntax)
+}
+
+// parseLayout converts a layout written in the given layout language to a regex
+func parseLayout(layout string, syntax layoutSyntax) (*parsedLayout, error) {
+	res := &parsedLayout{syntax: syntax, groups: make(map[string]bool)}
+
+	var regex strings.Builder
+	last := 0
+	for _, loc := range apacheDirectiveRegex.FindAllStringSubmatchIndex(layout, -1) {
+		// escape the literal text preceding the directive
+		regex.WriteString(regexp.QuoteMeta(layout[last:loc[0]]))
+		last = loc[1]
+
+		token := layout[loc[0]:loc[1]]
+		if token == "%%" {
+			regex.WriteString(`%`)
+			continue
+		}
+
+		modifiers := layout[loc[2]:loc[3]]
+		var arg string
+		if start := strings.Index(modifiers, "{"); start != -1 {
+			arg = modifiers[start+1 : strings.Index(modifiers, "}")]
+		}
+		// values wrapped in double quotes may contain spaces (Apache escapes any double quotes within them)
+		quoted := loc[0] > 0 && layout[loc[0]-1] == '"'
+
+		pattern, err := res.directiveRegex(token, layout[loc[4]:loc[5]], arg, quoted)
+		if err != nil {
+			return nil, err
+		}
+		regex.WriteString(pattern)
+	}
+	regex.WriteString(regexp.QuoteMeta(layout[last:]))
+
+	if regex.Len() > 0 {
+		res.regex = "^" + regex.String()
+	}
+	return res, nil
+}
+
+// directiveRegex returns the regex for a directive
+func (p *parsedLayout) directiveRegex(token, directive, arg string, quoted bool) (string, error) {
+	if directive == "t" {
+		return p.timeRegex(arg), nil
+	}
+
+	if _, ok := p.syntax.namedValues[directive]; ok {
+		if arg == "" {
+			return "", fmt.Errorf("unsupported token in format: %s (%%%s requires a {name})", token, directive)
+		}
+		// values with a dedicated column
+		for key, pattern := range p.syntax.regexMap {
+			// header names are case insensitive
+			if key == "%{"+arg+"}"+directive || (directive == "i" && strings.EqualFold(key, "%{"+arg+"}i")) {
+				return pattern, nil
+			}
+		}
+		return p.namedValueRegex(directive, arg, quoted), nil
+	}
+
+	key := "%" + directive
+	if arg != "" {
+		key = "%{" + arg + "}" + directive
+	}
+	if pattern, ok := p.syntax.regexMap[key]; ok {
+		return pattern, nil
+	}
+	return "", fmt.Errorf("unsupported token in format: %s", token)
+}
+
+// namedValueRegex returns the regex capturing a named value which is collected into a json column
+func (p *parsedLayout) namedValueRegex(directive, name string, quoted bool) string {
+	d := p.syntax.namedValues[directive]
+
+	// a name may be logged more than once - capture it with the same group
+	for _, v := range p.namedValues {
+		if v.column == d.column && v.name == name {
+			return namedValuePattern(v.group, quoted)
+		}
+	}
+
+	var group string
+	if d.column == "cookies" {
+		group = cookieGroupName(name)
+	} else {
+		group = d.groupPrefix + invalidGroupNameChars.ReplaceAllString(strings.ToLower(name), "_")
+	}
+	// a different name may map to the same group name (e.g. X-Id and X_Id)
+	for _, v := range p.namedValues {
+		if v.group == group {
+			group = fmt.Sprintf("%s_%d", group, len(p.namedValues))
+			break
+		}
+	}
+	p.namedValues = append(p.namedValues, namedValue{group: group, column: d.column, name: name})
+	return namedValuePattern(group, quoted)
+}
+
+// namedValuePattern returns the regex capturing a named value
+func namedValuePattern(group string, quoted bool) string {
+	if quoted {
+		return fmt.Sprintf(`(?P<%s>(?:\\.|[^"\\])*)`, group)
+	}
+	return fmt.Sprintf(`(?P<%s>[^ "]*)`, group)
+}
+
+// timeRegex returns the regex for a %t or %{format}t directive
+//
+// The format may be a strftime format, or one of sec, msec or usec (the time since the epoch) or msec_frac or usec_frac
+// (the fractional part of the seconds, which is added to the timestamp), optionally prefixed with begin: or end:.
+// A time logged by several strftime directives (e.g. a time zone logged separately with %{%z}t) is captured in parts,
+// which are combined by the mapper.
+func (p *parsedLayout) timeRegex(format string) string {
+	format = strings.TrimPrefix(format, "begin:")
+	format = strings.TrimPrefix(format, "end:")
+
+	switch format {
+	case "":
+		// %t is only captured if it is the first part of the timestamp
+		if len(p.timestampFormats) > 0 {
+			return `\[[^\]]*\]`
+		}
+		p.timestampFormats = append(p.timestampFormats, clfTimeFormat)
+		return `\[(?P<timestamp>[^\]]*)\]`
+	case "sec", "msec", "usec", "msec_frac", "usec_frac":
+		group := "timestamp_" + format
+		if p.groups[group] {
+			return `\d+`
+		}
+		p.groups[group] = true
+		return fmt.Sprintf(`(?P<%s>\d+)`, group)
+	}
+
+	group := "timestamp"
+	if len(p.timestampFormats) > 0 {
+		group = fmt.Sprintf("timestamp_%d", len(p.timestampFormats)+1)
+	}
+	p.timestampFormats = append(p.timestampFormats, format)
+	return fmt.Sprintf(`(?P<%s>%s)`, group, timeFormatToRegex(format))
+}
+
+// epochUnits are the units of the time since the epoch logged by %{sec}t, %{msec}t and %{usec}t
+var epochUnits = []struct {
+	name string
+	unit time.Duration
+}{
+	{"sec", time.Second},
+	{"msec", time.Millisecond},
+	{"usec", time.Microsecond},
+}
+
+// applyTimestampParts combines the parts of a timestamp logged by several strftime directives, populates the
+// timestamp field from the time since the epoch (%{sec}t, %{msec}t or %{usec}t) if it was not logged as a formatted
+// time, and adds any fractional seconds (%{msec_frac}t or %{usec_frac}t)
+func applyTimestampParts(fields map[string]string, timestampFormats []string) {
+	if len(timestampFormats) > 1 {
+		values := []string{fields["timestamp"]}
+		for i := 2; i <= len(timestampFormats); i++ {
+			group := fmt.Sprintf("timestamp_%d", i)
+			values = append(values, fields[group])
+			delete(fields, group)
+		}
+		// if the parts cannot be combined the first part is left to be parsed alone
+		if t, ok := parseTimestampParts(values, timestampFormats); ok {
+			fields["timestamp"] = t.Format(time.RFC3339Nano)
+		}
+	}
+
+	for _, u := range epochUnits {
+		value, ok := fields["timestamp_"+u.name]
+		if !ok {
+			continue
+		}
+		delete(fields, "timestamp_"+u.name)
+		if _, ok := fields["timestamp"]; ok {
+			continue
+		}
+		// leave values which are not a number to fail timestamp parsing
+		fields["timestamp"] = value
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			fields["timestamp"] = time.Unix(0, 0).Add(time.Duration(n) * u.unit).UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	for _, u := range epochUnits[1:] {
+		value, ok := fields["timestamp_"+u.name+"_frac"]
+		if !ok {
+			continue
+		}
+		delete(fields, "timestamp_"+u.name+"_frac")
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		t, err := helpers.ParseTime(fields["timestamp"])
+		// do not add the fraction to a timestamp which already has one
+		if err != nil || t.Nanosecond() != 0 {
+			continue
+		}
+		fields["timestamp"] = t.Add(time.Duration(n) * u.unit).Format(time.RFC3339Nano)
+	}
+}
+
+// parseTimestampParts parses the parts of a timestamp using their strftime formats
+func parseTimestampParts(values []string, formats []string) (time.Time, bool) {
+	layouts := make([]string, len(formats))
+	for i, format := range formats {
+		layout, ok := strftimeToLayout(format)
+		if !ok {
+			return time.Time{}, false
+		}
+		layouts[i] = layout
+	}
+	t, err := time.Parse(strings.Join(layouts, "\x00"), strings.Join(values, "\x00"))
+	return t, err == nil
+}
+
+// clfTimeFormat is the strftime format of the time logged by %t
+const clfTimeFormat = "%d/%b/%Y:%H:%M:%S %z"
+
+// strftimeLayouts are the go time layouts of the strftime conversion specifications which can be parsed
+var strftimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'D': "01/02/06",
+	'e': "_2",
+	'F': "2006-01-02",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'R': "15:04",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// strftimeToLayout converts a strftime format to a go time layout
+// false is returned if the format contains a conversion specification which cannot be parsed
+func strftimeToLayout(format string) (string, bool) {
+	var res strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			res.WriteByte(format[i])
+			continue
+		}
+		if i+1 == len(format) {
+			return "", false
+		}
+		layout, ok := strftimeLayouts[format[i+1]]
+		if !ok {
+			return "", false
+		}
+		res.WriteString(layout)
+		i++
+	}
+	return res.String(), true
+}
+
+// applyNamedValues collects the named values captured by the layout into their json columns
+// the value of each column is a json object keyed by the header, variable, note, cookie or trailer name
+func applyNamedValues(fields map[string]string, namedValues []namedValue) {
+	columns := make(map[string]map[string]string)
+	for _, v := range namedValues {
+		value, ok := fields[v.group]
+		if !ok {
+			continue
+		}
+		// cookie fields are retained, as the session cookie is read from them
+		if v.column != "cookies" {
+			delete(fields, v.group)
+		}
+		if value == "" || value == AccessLogTableNilValue {
+			continue
+		}
+		if columns[v.column] == nil {
+			columns[v.column] = make(map[string]string)
+		}
+		columns[v.column][v.name] = value
+	}
+
+	for column, values := range columns {
+		// a map of strings cannot fail to marshal
+		b, _ := json.Marshal(values)
+		fields[column] = string(b)
+	}
+}
+
+// applyPeerAddr uses the address of the underlying connection peer (%{c}a or %{c}h) as the client address
+// if the layout does not log the client address (%a or %h)
+func applyPeerAddr(fields map[string]string) {
+	if _, ok := fields["remote_addr"]; ok {
+		return
+	}
+	if peer, ok := fields["peer_addr"]; ok {
+		fields["remote_addr"] = peer
+	}
+}
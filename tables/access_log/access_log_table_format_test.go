@@ -1,8 +1,14 @@
 package access_log
 
 import (
+	"context"
 	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
 )
 
 func Test_AccessLogTableFormat_GetRegex(t *testing.T) {
@@ -133,7 +139,7 @@ func Test_AccessLogTableFormat_GetRegex(t *testing.T) {
 		{
 			name: "Unsupported token",
 			args: args{
-				layout:  `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i" "%{X-RANDOM-IP}i"`,
+				layout:  `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i" "%{X-RANDOM-IP}j"`,
 				logLine: `192.168.1.1 - john [24/Feb/2025:12:34:56 +0000] "GET /data HTTP/1.1" 200 4321 "https://example.com" "Mozilla/5.0" "203.0.113.42"`,
 			},
 			want: map[string]string{
@@ -352,6 +358,141 @@ func Test_AccessLogTableFormat_GetRegex(t *testing.T) {
 				"client_port":     "54321", // %{remote}p: client_port not server_port
 			},
 		},
+		{
+			name: "Custom: Host header, log ID and unique ID",
+			args: args{
+				layout:  `%h %l %u %t "%r" %>s %b "%{Host}i" %L %{UNIQUE_ID}e`,
+				logLine: `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 1234 "www.example.com:8080" AAAAAAAAAAAAAAAAAAAAAAA Z7xlYH8AAQEAAAKx0dAAAAAM`,
+			},
+			want: map[string]string{
+				"remote_addr":     "192.168.1.1",
+				"request_uri":     "/index.html",
+				"status":          "200",
+				"body_bytes_sent": "1234",
+				"http_host":       "www.example.com:8080",
+				"log_id":          "AAAAAAAAAAAAAAAAAAAAAAA",
+				"unique_id":       "Z7xlYH8AAQEAAAKx0dAAAAAM",
+			},
+		},
+		{
+			name: "Custom: X-Forwarded-For header",
+			args: args{
+				layout:  `%h %l %u %t "%r" %>s %b "%{X-Forwarded-For}i"`,
+				logLine: `10.0.0.1 - - [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 1234 "198.51.100.7, 10.0.0.2"`,
+			},
+			want: map[string]string{
+				"remote_addr":          "10.0.0.1",
+				"status":               "200",
+				"http_x_forwarded_for": "198.51.100.7, 10.0.0.2",
+			},
+		},
+		{
+			name: "Custom: Cookie values",
+			args: args{
+				layout:  `%h %l %u %t "%r" %>s %b %{JSESSIONID}C "%{session-id}C"`,
+				logLine: `10.0.0.1 - - [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 1234 8F3A2B1C "s-42"`,
+			},
+			want: map[string]string{
+				"remote_addr":       "10.0.0.1",
+				"cookie_JSESSIONID": "8F3A2B1C",
+				"cookie_session_id": "s-42",
+			},
+		},
+		{
+			name: "Custom: Request and response headers, environment variables, notes and trailers",
+			args: args{
+				layout:  `%h %t "%r" %>s "%{X-Request-Id}i" %{Content-Type}o %{HTTPS}e %{ratio}n "%{Grpc-Status}^ti" %{Grpc-Message}^to`,
+				logLine: `10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 "abc 123" text/html on 42 "0" OK`,
+			},
+			want: map[string]string{
+				"remote_addr":                    "10.0.0.1",
+				"status":                         "200",
+				"request_header__x_request_id":   "abc 123",
+				"response_header__content_type":  "text/html",
+				"environment_variable__https":    "on",
+				"note__ratio":                    "42",
+				"request_trailer__grpc_status":   "0",
+				"response_trailer__grpc_message": "OK",
+			},
+		},
+		{
+			name: "Custom: Header names are case insensitive",
+			args: args{
+				layout:  `%h %t "%r" %>s "%{referer}i" "%{USER-AGENT}i"`,
+				logLine: `10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 "https://example.com" "Mozilla/5.0"`,
+			},
+			want: map[string]string{
+				"http_referer":    "https://example.com",
+				"http_user_agent": "Mozilla/5.0",
+			},
+		},
+		{
+			name: "Custom: Status code conditions and original/final request modifiers",
+			args: args{
+				layout:  `%h %t "%<r" %<s %>s %>U %400,501{User-agent}i %!200,304,302{Referer}i %<T`,
+				logLine: `10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET /old HTTP/1.1" 302 200 /new - https://example.com 1`,
+			},
+			want: map[string]string{
+				"request_method":  "GET",
+				"status":          "200",
+				"request_uri":     "/new",
+				"http_user_agent": "-",
+				"http_referer":    "https://example.com",
+				"request_time":    "1",
+			},
+		},
+		{
+			name: "Custom: Connection directives",
+			args: args{
+				layout:  `%a %{c}a %h %{c}L %L %{tid}P %{hextid}P %{pid}P %{local}p %{canonical}p %k %X`,
+				logLine: `198.51.100.7 10.0.0.1 198.51.100.7 AAAAAAAAAAA AAAAAAAAAAAAAAAAAAAAAAA 140234 0x7f8c 1234 8443 443 3 +`,
+			},
+			want: map[string]string{
+				"remote_addr":        "198.51.100.7",
+				"peer_addr":          "10.0.0.1",
+				"connection_log_id":  "AAAAAAAAAAA",
+				"log_id":             "AAAAAAAAAAAAAAAAAAAAAAA",
+				"thread_id":          "140234",
+				"hex_thread_id":      "0x7f8c",
+				"pid":                "1234",
+				"apache_port":        "8443",
+				"server_port":        "443",
+				"keepalive_requests": "3",
+				"connection_status":  "+",
+			},
+		},
+		{
+			name: "Custom: Timestamp with milliseconds and a separate time zone",
+			args: args{
+				layout:  `%h [%{%d/%b/%Y:%H:%M:%S}t.%{msec_frac}t %{%z}t] "%r" %>s`,
+				logLine: `10.0.0.1 [24/Feb/2025:12:34:56.789 +0100] "GET / HTTP/1.1" 200`,
+			},
+			want: map[string]string{
+				"timestamp":           "24/Feb/2025:12:34:56",
+				"timestamp_2":         "+0100",
+				"timestamp_msec_frac": "789",
+				"status":              "200",
+			},
+		},
+		{
+			name: "Custom: Request begin and end time since the epoch",
+			args: args{
+				layout:  `%h %{begin:msec}t %{end:msec}t "%r" %>s`,
+				logLine: `10.0.0.1 1740400496123 1740400496456 "GET / HTTP/1.1" 200`,
+			},
+			want: map[string]string{
+				"timestamp_msec": "1740400496123",
+				"status":         "200",
+			},
+		},
+		{
+			name: "Unsupported token: named value directive without a name",
+			args: args{
+				layout:  `%h %t "%r" %>s %i`,
+				logLine: `10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200 -`,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -367,6 +508,9 @@ func Test_AccessLogTableFormat_GetRegex(t *testing.T) {
 				}
 				t.Fatalf("unexpected error: %v", err)
 			}
+			if tt.wantErr {
+				t.Fatalf("expected error, got regex %s", got)
+			}
 
 			// validate regex compiles
 			re, err := regexp.Compile(got)
@@ -401,3 +545,272 @@ func Test_AccessLogTableFormat_GetRegex(t *testing.T) {
 	}
 
 }
+
+func Test_AccessLogTableFormat_GetMapper_Directives(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		logLine string
+		want    map[string]string
+		// fields which should not be present in the mapped row
+		wantMissing []string
+	}{
+		{
+			name:    "named values are collected into json columns",
+			layout:  `%h %t "%r" %>s "%{X-Request-Id}i" "%{X-Trace}i" %{Content-Type}o %{sessid}C %{HTTPS}e`,
+			logLine: `10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200 "abc \"123\"" "-" text/html s-42 on`,
+			want: map[string]string{
+				"request_headers":       `{"X-Request-Id":"abc \\\"123\\\""}`,
+				"response_headers":      `{"Content-Type":"text/html"}`,
+				"cookies":               `{"sessid":"s-42"}`,
+				"cookie_sessid":         "s-42",
+				"environment_variables": `{"HTTPS":"on"}`,
+			},
+			wantMissing: []string{"request_header__x_request_id", "request_header__x_trace", "response_header__content_type", "environment_variable__https"},
+		},
+		{
+			name:    "fractional seconds are added to the timestamp",
+			layout:  `%h [%{%d/%b/%Y:%H:%M:%S}t.%{usec_frac}t %{%z}t] "%r" %>s`,
+			logLine: `10.0.0.1 [24/Feb/2025:12:34:56.000789 +0100] "GET / HTTP/1.1" 200`,
+			want: map[string]string{
+				"timestamp": "2025-02-24T12:34:56.000789+01:00",
+			},
+			wantMissing: []string{"timestamp_2", "timestamp_usec_frac"},
+		},
+		{
+			name:    "time since the epoch",
+			layout:  `%h %{msec}t "%r" %>s`,
+			logLine: `10.0.0.1 1740400496123 "GET / HTTP/1.1" 200`,
+			want: map[string]string{
+				"timestamp": "2025-02-24T12:34:56.123Z",
+			},
+			wantMissing: []string{"timestamp_msec"},
+		},
+		{
+			name:    "peer address is used as the client address if it is not logged",
+			layout:  `%{c}a %t "%r" %>s`,
+			logLine: `10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200`,
+			want: map[string]string{
+				"remote_addr": "10.0.0.1",
+				"peer_addr":   "10.0.0.1",
+			},
+		},
+		{
+			name:    "peer address does not replace the client address",
+			layout:  `%a %{c}a %t "%r" %>s`,
+			logLine: `198.51.100.7 10.0.0.1 [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200`,
+			want: map[string]string{
+				"remote_addr": "198.51.100.7",
+				"peer_addr":   "10.0.0.1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := &AccessLogTableFormat{Name: "test", Layout: tt.layout}
+			mapper, err := format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+			for _, k := range tt.wantMissing {
+				if got, ok := row.GetSourceValue(k); ok {
+					t.Errorf("%s: got %q, want missing", k, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_AccessLogTableFormat_ParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		hcl     string
+		wantErr string
+	}{
+		{
+			name: "Layout and options",
+			hcl: `layout = "%h %l %u %t \"%r\" %>s %b %%{sessid}C"
+session_timeout = "15m"
+session_cookie = "sessid"`,
+		},
+		{
+			name: "Unsupported argument",
+			hcl: `layout = "%h %l %u %t \"%r\" %>s %b"
+include_raw_lines = true`,
+			wantErr: "unsupported argument 'include_raw_lines'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configData := types.NewFormatConfigData([]byte(tt.hcl), hcl.Range{Filename: "test.hcl"}, AccessLogTableIdentifier)
+			format, err := formats.ParseFormat(configData, map[string]func() formats.Format{AccessLogTableIdentifier: NewAccessLogTableFormat})
+			if err == nil {
+				err = format.Validate()
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := format.(*AccessLogTableFormat)
+			if got.Layout == "" || got.SessionTimeout == nil || *got.SessionTimeout != "15m" {
+				t.Errorf("got layout %q, session_timeout %v", got.Layout, got.SessionTimeout)
+			}
+		})
+	}
+}
+
+func Test_AccessLogTableFormatPresets(t *testing.T) {
+	tests := []struct {
+		preset  string
+		logLine string
+		want    map[string]string
+	}{
+		{
+			preset:  "debian_vhost_combined",
+			logLine: `www.example.com:443 203.0.113.42 - - [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 3477 "https://www.google.com/" "Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0"`,
+			want: map[string]string{
+				"server_name":     "www.example.com",
+				"server_port":     "443",
+				"remote_addr":     "203.0.113.42",
+				"timestamp":       "24/Feb/2025:12:34:56 +0000",
+				"request_uri":     "/index.html",
+				"status":          "200",
+				"bytes_sent":      "3477",
+				"http_referer":    "https://www.google.com/",
+				"http_user_agent": "Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0",
+			},
+		},
+		{
+			preset:  "debian_combined",
+			logLine: `203.0.113.42 - - [24/Feb/2025:12:34:56 +0000] "GET /favicon.ico HTTP/1.1" 404 487 "https://www.example.com/" "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"`,
+			want: map[string]string{
+				"remote_addr":  "203.0.113.42",
+				"request_uri":  "/favicon.ico",
+				"status":       "404",
+				"bytes_sent":   "487",
+				"http_referer": "https://www.example.com/",
+			},
+		},
+		{
+			preset:  "debian_common",
+			logLine: `::1 - - [24/Feb/2025:12:34:56 +0000] "OPTIONS * HTTP/1.0" 200 126`,
+			want: map[string]string{
+				"remote_addr":    "::1",
+				"request_method": "OPTIONS",
+				"request_uri":    "*",
+				"bytes_sent":     "126",
+			},
+		},
+		{
+			preset:  "debian_referer",
+			logLine: `https://www.example.com/blog/ -> /images/header.png`,
+			want: map[string]string{
+				"http_referer": "https://www.example.com/blog/",
+				"request_uri":  "/images/header.png",
+			},
+		},
+		{
+			preset:  "debian_agent",
+			logLine: `Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)`,
+			want: map[string]string{
+				"http_user_agent": "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			},
+		},
+		{
+			preset:  "rhel_combinedio",
+			logLine: `198.51.100.7 - admin [24/Feb/2025:12:34:56 -0500] "POST /wp-login.php HTTP/1.1" 302 - "https://blog.example.com/wp-login.php" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.3 Safari/605.1.15" 1024 745`,
+			want: map[string]string{
+				"remote_user":     "admin",
+				"request_method":  "POST",
+				"status":          "302",
+				"body_bytes_sent": "-",
+				"bytes_received":  "1024",
+				"bytes_sent":      "745",
+			},
+		},
+		{
+			preset:  "cpanel_domlog",
+			logLine: `192.0.2.15 - - [24/Feb/2025:12:34:56 -0600] "GET /wp-content/themes/twentytwentyfive/style.css?ver=1.0 HTTP/2.0" 200 1372 "https://example.com/" "Mozilla/5.0 (iPhone; CPU iPhone OS 18_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.3 Mobile/15E148 Safari/604.1"`,
+			want: map[string]string{
+				"remote_addr":     "192.0.2.15",
+				"request_uri":     "/wp-content/themes/twentytwentyfive/style.css?ver=1.0",
+				"server_protocol": "HTTP/2.0",
+				"body_bytes_sent": "1372",
+			},
+		},
+		{
+			preset:  "plesk",
+			logLine: `203.0.113.42 - - [24/Feb/2025:12:34:56 +0100] "GET /robots.txt HTTP/1.1" 200 297 "-" "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"`,
+			want: map[string]string{
+				"request_uri":     "/robots.txt",
+				"bytes_sent":      "297",
+				"http_referer":    "-",
+				"http_user_agent": "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			},
+		},
+		{
+			preset:  "bitnami",
+			logLine: `127.0.0.1 - - [24/Feb/2025:12:34:56 +0000] "GET /index.html HTTP/1.1" 200 45`,
+			want: map[string]string{
+				"remote_addr":     "127.0.0.1",
+				"body_bytes_sent": "45",
+			},
+		},
+		{
+			preset:  "httpd_docker",
+			logLine: `172.17.0.1 - - [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 304 -`,
+			want: map[string]string{
+				"remote_addr":     "172.17.0.1",
+				"status":          "304",
+				"body_bytes_sent": "-",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			var format *AccessLogTableFormat
+			for _, p := range AccessLogTableFormatPresets {
+				if f, ok := p.(*AccessLogTableFormat); ok && f.Name == tt.preset {
+					format = f
+				}
+			}
+			if format == nil {
+				t.Fatalf("preset %s not found", tt.preset)
+			}
+			if err := format.Validate(); err != nil {
+				t.Fatalf("unexpected validation error: %v", err)
+			}
+
+			mapper, err := format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
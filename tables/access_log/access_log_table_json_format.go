@@ -0,0 +1,82 @@
+package access_log
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const AccessLogJsonFormatIdentifier = "apache_access_log_json"
+
+// AccessLogJsonFormat is the format of access logs written as one JSON object per line, using a LogFormat such as
+// "{ \"time\":\"%{%Y-%m-%dT%H:%M:%S%z}t\", \"remote_addr\":\"%a\", \"request\":\"%r\", \"status\":\"%>s\" }"
+//
+// Keys with the same name as a column are mapped to that column, any other keys are mapped using Fields.
+type AccessLogJsonFormat struct {
+	AccessLogOptions
+	// required to allow partial decoding, as the options are decoded from the same body
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the column each JSON key is mapped to, keyed by JSON key
+	Fields map[string]string `hcl:"fields,optional"`
+}
+
+func NewAccessLogJsonFormat() formats.Format {
+	return &AccessLogJsonFormat{}
+}
+
+func (a *AccessLogJsonFormat) Validate() error {
+	if err := a.validateOptions(a.Remain); err != nil {
+		return err
+	}
+	return validateKeyedFields(a.Fields)
+}
+
+// Identifier returns the format TYPE
+func (a *AccessLogJsonFormat) Identifier() string {
+	return AccessLogJsonFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *AccessLogJsonFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *AccessLogJsonFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *AccessLogJsonFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *AccessLogJsonFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &accessLogKeyValueMapper{
+		identifier:     "apache_access_log_json_mapper",
+		decode:         decodeJsonLine,
+		fields:         a.Fields,
+		columns:        keyedFormatColumns(),
+		includeRawLine: a.includesRawLine(),
+		keepUnparsed:   a.keepsUnparsedLines(),
+	}, nil
+}
+
+// GetRegex returns N/A, as JSON lines are not parsed with a regex
+func (a *AccessLogJsonFormat) GetRegex() (string, error) {
+	return "N/A", nil
+}
+
+func (a *AccessLogJsonFormat) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	for key, column := range a.Fields {
+		properties["fields."+key] = column
+	}
+	a.addProperties(properties)
+	return properties
+}
@@ -0,0 +1,301 @@
+package access_log
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func Test_AccessLogJsonFormat_GetMapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  *AccessLogJsonFormat
+		logLine string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "Keys named after columns",
+			format:  &AccessLogJsonFormat{},
+			logLine: `{"remote_addr":"192.168.1.1","request_line":"GET /index.html?q=1 HTTP/1.1","status":200,"bytes_sent":"5123","timestamp":"2025-02-24T12:34:56+0000","unknown":"ignored"}`,
+			want: map[string]string{
+				"remote_addr":     "192.168.1.1",
+				"request_method":  "GET",
+				"request_uri":     "/index.html?q=1",
+				"server_protocol": "HTTP/1.1",
+				"status":          "200",
+				"bytes_sent":      "5123",
+				"timestamp":       "2025-02-24T12:34:56+00:00",
+			},
+		},
+		{
+			name: "Configured key mapping",
+			format: &AccessLogJsonFormat{
+				Fields: map[string]string{"ip": "remote_addr", "ua": "http_user_agent", "headers": "request_headers"},
+			},
+			logLine: `{"ip":"10.0.0.1","ua":"curl/8.5.0 \"quoted\"","headers":{"X-Request-Id":"abc","X-Retry":3}}`,
+			want: map[string]string{
+				"remote_addr":     "10.0.0.1",
+				"http_user_agent": `curl/8.5.0 "quoted"`,
+				"request_headers": `{"X-Request-Id":"abc","X-Retry":"3"}`,
+			},
+		},
+		{
+			name:    "Configured key mapping takes precedence over a key named after the column",
+			format:  &AccessLogJsonFormat{Fields: map[string]string{"ip": "remote_addr"}},
+			logLine: `{"remote_addr":"10.0.0.9","ip":"10.0.0.1","status":"200"}`,
+			want: map[string]string{
+				"remote_addr": "10.0.0.1",
+				"status":      "200",
+			},
+		},
+		{
+			name:    "Key named after a column when the mapped key is not logged",
+			format:  &AccessLogJsonFormat{Fields: map[string]string{"ip": "remote_addr"}},
+			logLine: `{"remote_addr":"10.0.0.9"}`,
+			want: map[string]string{
+				"remote_addr": "10.0.0.9",
+			},
+		},
+		{
+			name:    "Apache hex escapes",
+			format:  &AccessLogJsonFormat{},
+			logLine: `{"request_line":"\x16\x03\x01\x00","http_referer":"C:\\x41"}`,
+			want: map[string]string{
+				"request_line":         "\x16\x03\x01\x00",
+				"is_malformed_request": "true",
+				"http_referer":         `C:\x41`,
+			},
+		},
+		{
+			name:    "Derived columns are not mapped from keys",
+			format:  &AccessLogJsonFormat{},
+			logLine: `{"status":"500","is_error":"false","session_id":"forged","threat_categories":"none","is_redacted":"true","threat_rules_version":"0"}`,
+			want: map[string]string{
+				"status":               "500",
+				"is_error":             "",
+				"session_id":           "",
+				"threat_categories":    "",
+				"is_redacted":          "",
+				"threat_rules_version": "",
+			},
+		},
+		{
+			name:    "Invalid JSON",
+			format:  &AccessLogJsonFormat{},
+			logLine: `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200 5123`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid JSON kept as an unparsed row",
+			format:  &AccessLogJsonFormat{AccessLogOptions: AccessLogOptions{KeepUnparsedLines: &[]bool{true}[0]}},
+			logLine: `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200 5123`,
+			want: map[string]string{
+				"raw_line":    `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "GET / HTTP/1.1" 200 5123`,
+				"is_unparsed": "true",
+				"remote_addr": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := tt.format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			if tt.wantErr {
+				t.Fatalf("expected error, got row %v", row)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+			if _, ok := row.GetSourceValue("unknown"); ok {
+				t.Errorf("unmapped key should not be included in the row")
+			}
+		})
+	}
+}
+
+func Test_AccessLogJsonFormat_Validate(t *testing.T) {
+	format := &AccessLogJsonFormat{Fields: map[string]string{"time": "timestamp", "ip": "client_ip"}}
+	if err := format.Validate(); err == nil {
+		t.Errorf("expected error for a key mapped to an unknown column")
+	}
+	format = &AccessLogJsonFormat{Fields: map[string]string{"sid": "session_id"}}
+	if err := format.Validate(); err == nil {
+		t.Errorf("expected error for a key mapped to a derived column")
+	}
+	format = &AccessLogJsonFormat{Fields: map[string]string{"ip": "remote_addr", "client": "remote_addr"}}
+	if err := format.Validate(); err == nil {
+		t.Errorf("expected error for two keys mapped to the same column")
+	}
+	for _, preset := range AccessLogJsonFormatPresets {
+		if err := preset.Validate(); err != nil {
+			t.Errorf("preset %s: unexpected validation error: %v", preset.GetName(), err)
+		}
+	}
+}
+
+func Test_AccessLogJsonFormat_ParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		hcl     string
+		want    *AccessLogOptions
+		wantErr string
+	}{
+		{
+			name: "Options",
+			hcl: `fields = { ip = "remote_addr" }
+ip_anonymization = "truncate"
+anonymization_key = "secret"
+redact_query_parameters = ["token"]
+parse_mode = "lenient"`,
+			want: &AccessLogOptions{
+				IpAnonymization:       &[]string{IpAnonymizationTruncate}[0],
+				AnonymizationKey:      &[]string{"secret"}[0],
+				RedactQueryParameters: []string{"token"},
+				ParseMode:             &[]string{ParseModeLenient}[0],
+			},
+		},
+		{
+			name:    "Unsupported argument",
+			hcl:     `ip_anonymisation = "truncate"`,
+			wantErr: "unsupported argument 'ip_anonymisation'",
+		},
+		{
+			name:    "Session cookie",
+			hcl:     `session_cookie = "sessid"`,
+			wantErr: "session_cookie is only supported by apache_access_log formats",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configData := types.NewFormatConfigData([]byte(tt.hcl), hcl.Range{Filename: "test.hcl"}, AccessLogJsonFormatIdentifier)
+			format, err := formats.ParseFormat(configData, map[string]func() formats.Format{AccessLogJsonFormatIdentifier: NewAccessLogJsonFormat})
+			if err == nil {
+				err = format.Validate()
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := format.(*AccessLogJsonFormat)
+			if got.Fields["ip"] != "remote_addr" {
+				t.Errorf("fields: got %v", got.Fields)
+			}
+			got.AccessLogOptions.Remain = nil
+			if !reflect.DeepEqual(&got.AccessLogOptions, tt.want) {
+				t.Errorf("got options %+v, want %+v", got.AccessLogOptions, *tt.want)
+			}
+		})
+	}
+}
+
+func Test_AccessLogJsonFormat_EnrichRow(t *testing.T) {
+	truncate := IpAnonymizationTruncate
+	key := "secret"
+	format := &AccessLogJsonFormat{
+		AccessLogOptions: AccessLogOptions{
+			IpAnonymization:       &truncate,
+			AnonymizationKey:      &key,
+			RedactQueryParameters: []string{"token"},
+		},
+	}
+	row := enrichTestRow(t, format, map[string]string{
+		"remote_addr": "203.0.113.42",
+		"request_uri": "/reset?token=abc123",
+	})
+	if got := row.OutputColumns["remote_addr"]; got != "203.0.113.0" {
+		t.Errorf("remote_addr: got %v, want 203.0.113.0", got)
+	}
+	if got := row.OutputColumns["request_uri"]; got != "/reset?token=[REDACTED]" {
+		t.Errorf("request_uri: got %v, want /reset?token=[REDACTED]", got)
+	}
+}
+
+func Test_AccessLogJsonFormatPresets(t *testing.T) {
+	tests := []struct {
+		preset  string
+		logLine string
+		want    map[string]string
+	}{
+		{
+			preset:  "loggly",
+			logLine: `{ "time":"[24/Feb/2025:12:34:56 +0000]", "remoteIP":"203.0.113.42", "host":"www.example.com", "request":"/search", "query":"?q=apache", "method":"GET", "status":"200", "userAgent":"Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0", "referer":"-" }`,
+			want: map[string]string{
+				"timestamp":       "24/Feb/2025:12:34:56 +0000",
+				"remote_addr":     "203.0.113.42",
+				"server_name":     "www.example.com",
+				"request_uri":     "/search",
+				"query_string":    "?q=apache",
+				"request_method":  "GET",
+				"status":          "200",
+				"http_user_agent": "Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0",
+				"http_referer":    "-",
+			},
+		},
+		{
+			preset:  "combined",
+			logLine: `{ "time":"2025-02-24T12:34:56-0500", "remote_addr":"198.51.100.7", "remote_user":"-", "request":"POST /api/login HTTP/2.0", "status":"401", "bytes_sent":"612", "http_referer":"https://app.example.com/", "http_user_agent":"okhttp/4.12.0", "request_time_us":"1532" }`,
+			want: map[string]string{
+				"timestamp":       "2025-02-24T12:34:56-05:00",
+				"remote_addr":     "198.51.100.7",
+				"request_method":  "POST",
+				"request_uri":     "/api/login",
+				"server_protocol": "HTTP/2.0",
+				"status":          "401",
+				"bytes_sent":      "612",
+				"request_time_us": "1532",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			var format *AccessLogJsonFormat
+			for _, p := range AccessLogJsonFormatPresets {
+				if f, ok := p.(*AccessLogJsonFormat); ok && f.Name == tt.preset {
+					format = f
+				}
+			}
+			if format == nil {
+				t.Fatalf("preset %s not found", tt.preset)
+			}
+
+			mapper, err := format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package access_log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeJsonLine decodes a JSON object to its keys and values, converting any \xhh escapes written by Apache
+// to JSON \u00hh escapes
+func decodeJsonLine(input string) (map[string]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(convertHexEscapes(input)))
+	// keep numbers as they were logged, rather than converting them to floats
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	res := make(map[string]string, len(values))
+	for key, value := range values {
+		if s, ok := jsonValueToString(value); ok {
+			res[key] = s
+		}
+	}
+	return res, nil
+}
+
+// convertHexEscapes converts the \xhh escapes Apache writes for non-printable characters, which are not valid
+// JSON escapes, to \u00hh escapes
+func convertHexEscapes(input string) string {
+	if !strings.Contains(input, `\x`) {
+		return input
+	}
+	var b strings.Builder
+	for i := 0; i < len(input); i++ {
+		if input[i] != '\\' || i+1 == len(input) {
+			b.WriteByte(input[i])
+			continue
+		}
+		if input[i+1] == 'x' && i+3 < len(input) && isHexDigit(input[i+2]) && isHexDigit(input[i+3]) {
+			b.WriteString(`\u00`)
+			b.WriteString(input[i+2 : i+4])
+			i += 3
+			continue
+		}
+		// any other escape is copied as is
+		b.WriteString(input[i : i+2])
+		i++
+	}
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// jsonValueToString converts a JSON value to the string value of a field - objects are converted to a JSON object
+// of string values (e.g. for the request_headers column) and null values are skipped
+func jsonValueToString(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case map[string]any:
+		values := make(map[string]string, len(v))
+		for name, nested := range v {
+			if s, ok := jsonValueToString(nested); ok {
+				values[name] = s
+			}
+		}
+		b, err := json.Marshal(values)
+		return string(b), err == nil
+	default:
+		// arrays are kept as JSON
+		b, err := json.Marshal(v)
+		return string(b), err == nil
+	}
+}
@@ -0,0 +1,163 @@
+package access_log
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// an ISO 8601 time with a %z offset (e.g. 2025-02-24T12:34:56+0000), which has no colon in the offset
+var isoTimeOffsetRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?[+-]\d{2})(\d{2})$`)
+
+// accessLogKeyValueMapper maps a log line made up of keyed values (e.g. a JSON object) to a row, mapping each key
+// to a column - the line is decoded into its keys and values by the decode function of the format
+type accessLogKeyValueMapper struct {
+	identifier string
+	decode     func(string) (map[string]string, error)
+	// the column each key is mapped to, for keys which are not named after a column
+	fields map[string]string
+	// the columns keys may be mapped to
+	columns []string
+	// if set, the original log line is included in the raw_line field
+	includeRawLine bool
+	// if set, lines which cannot be decoded are mapped to an unparsed row rather than returning an error
+	keepUnparsed bool
+	// the column type of each field - if set (in lenient parse mode), fields which cannot be converted to
+	// their column type are set to null rather than causing the row to be dropped
+	fieldTypes map[string]string
+}
+
+func (m *accessLogKeyValueMapper) Identifier() string {
+	return m.identifier
+}
+
+// Map maps a log line, which is either a string or an accessLogLine read by the accessLogLoader
+func (m *accessLogKeyValueMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var input string
+	var lineNumber, lineOffset int64
+	switch line := a.(type) {
+	case string:
+		input = line
+	case *accessLogLine:
+		input = line.text
+		lineNumber = line.number
+		lineOffset = line.offset
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields := make(map[string]string)
+	values, err := m.decode(input)
+	switch {
+	case err == nil:
+		// keys explicitly mapped to a column take precedence over a key named after the column, so the value kept
+		// does not depend on the order of the keys
+		for key, value := range values {
+			if column, ok := m.fields[key]; ok && slices.Contains(m.columns, column) {
+				fields[column] = value
+			}
+		}
+		for key, value := range values {
+			if _, ok := m.fields[key]; ok {
+				continue
+			}
+			if _, ok := fields[key]; !ok && slices.Contains(m.columns, key) {
+				fields[key] = value
+			}
+		}
+		if ts, ok := fields["timestamp"]; ok {
+			fields["timestamp"] = normaliseKeyedTimestamp(ts)
+		}
+		applyRequestLine(fields)
+		applyPeerAddr(fields)
+		if m.fieldTypes != nil {
+			validateFieldTypes(fields, m.fieldTypes)
+		}
+		if m.includeRawLine {
+			fields["raw_line"] = input
+		}
+		if m.keepUnparsed {
+			fields["is_unparsed"] = "false"
+		}
+	case m.keepUnparsed:
+		// the raw line is always included for unparsed rows, as it is the only record of the line
+		fields["raw_line"] = input
+		fields["is_unparsed"] = "true"
+		addParseError(fields, "line", err.Error())
+	default:
+		return nil, fmt.Errorf("error parsing log line: %w", err)
+	}
+
+	// provenance of lines read by the accessLogLoader
+	if lineNumber > 0 {
+		fields["log_line"] = strconv.FormatInt(lineNumber, 10)
+		fields["log_offset"] = strconv.FormatInt(lineOffset, 10)
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
+
+// normaliseKeyedTimestamp converts a logged time to a format which can be parsed as a timestamp, removing the
+// brackets logged by %t and adding a colon to a %z offset in an ISO 8601 time
+func normaliseKeyedTimestamp(ts string) string {
+	ts = strings.TrimSuffix(strings.TrimPrefix(ts, "["), "]")
+	return isoTimeOffsetRegex.ReplaceAllString(ts, "${1}:${2}")
+}
+
+// derivedColumns are the columns the plugin computes from other fields - these are always set by the plugin, so a
+// logged value cannot be used to forge them
+var derivedColumns = []string{
+	// provenance and unparsed lines
+	"raw_line", "parse_error", "is_unparsed", "log_file", "log_line", "log_offset",
+	// request line
+	"request_target_form", "is_malformed_request",
+	// status
+	"status_class", "status_text", "is_error", "is_client_error", "is_server_error",
+	// redaction, threats and sessions
+	"is_redacted", "threat_categories", "threat_rule_ids", "threat_rules_version", "threat_intel_matches", "session_id",
+}
+
+// keyedFormatColumns returns the columns a key may be mapped to - the columns of the table which are not set by
+// the plugin itself (tp_ columns and the derived columns)
+func keyedFormatColumns() []string {
+	var columns []string
+	for _, column := range (&AccessLogTable{}).GetTableDefinition().Columns {
+		switch {
+		case strings.HasPrefix(column.ColumnName, "tp_"):
+		case slices.Contains(derivedColumns, column.ColumnName):
+		default:
+			columns = append(columns, column.ColumnName)
+		}
+	}
+	return columns
+}
+
+// validateKeyedFields validates the columns keys are mapped to - each column may only be mapped to by one key
+func validateKeyedFields(fields map[string]string) error {
+	columns := keyedFormatColumns()
+	mappedKeys := make(map[string]string)
+	// sort the keys so the error is deterministic
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		column := fields[key]
+		if !slices.Contains(columns, column) {
+			return fmt.Errorf("invalid fields entry '%s': '%s' is not a column of %s", key, column, AccessLogTableIdentifier)
+		}
+		// the value of a line with both keys would depend on the order of the keys
+		if other, ok := mappedKeys[column]; ok {
+			return fmt.Errorf("invalid fields entry '%s': '%s' is also mapped to column '%s'", key, other, column)
+		}
+		mappedKeys[column] = key
+	}
+	return nil
+}
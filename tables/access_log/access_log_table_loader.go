@@ -0,0 +1,58 @@
+package access_log
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const AccessLogLoaderIdentifier = "apache_access_log_loader"
+
+// accessLogLine is a line read from an artifact by the accessLogLoader
+type accessLogLine struct {
+	text string
+	// the 1-based line number within the artifact
+	number int64
+	// the byte offset of the start of the line within the (decompressed) artifact
+	offset int64
+}
+
+// accessLogLoader is a Loader which reads an artifact line by line, including the line number and byte offset
+// of each line - gzip, zstd and zip compressed artifacts are decompressed based on the file extension
+type accessLogLoader struct{}
+
+func newAccessLogLoader() *accessLogLoader {
+	return &accessLogLoader{}
+}
+
+func (l *accessLogLoader) Identifier() string {
+	return AccessLogLoaderIdentifier
+}
+
+// Load implements Loader
+func (l *accessLogLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	slog.Debug("accessLogLoader Load", "path", info.LocalName)
+
+	reader, err := artifact.Open(info.LocalName)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			reader.Close()
+			close(dataChan)
+		}()
+
+		// lines longer than artifact.MaxLineLength are truncated
+		if err := artifact.ReadLines(ctx, reader, func(line artifact.Line) {
+			dataChan <- &types.RowData{Data: &accessLogLine{text: line.Text, number: line.Number, offset: line.Offset}}
+		}); err != nil {
+			slog.Error("Error while reading artifact", "path", info.LocalName, "error", err)
+		}
+		slog.Debug("accessLogLoader Load complete", "path", info.LocalName)
+	}()
+	return nil
+}
@@ -0,0 +1,44 @@
+package access_log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func Test_accessLogLoader_Load_Provenance(t *testing.T) {
+	content := "192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] \"GET / HTTP/1.1\" 200 10\r\n" +
+		"192.168.1.2 - - [24/Feb/2025:12:34:57 +0000] \"GET /a HTTP/1.1\" 404 20\n" +
+		"192.168.1.3 - - [24/Feb/2025:12:34:58 +0000] \"GET /b HTTP/1.1\" 200 30\n"
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dataChan := make(chan *types.RowData)
+	if err := newAccessLogLoader().Load(context.Background(), &types.DownloadedArtifactInfo{LocalName: path}, dataChan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var number int64
+	for data := range dataChan {
+		number++
+		line, ok := data.Data.(*accessLogLine)
+		if !ok {
+			t.Fatalf("expected *accessLogLine, got %T", data.Data)
+		}
+		if line.number != number {
+			t.Errorf("line number: got %d, want %d", line.number, number)
+		}
+		// the offset must point at the line in the file
+		if got := content[line.offset : line.offset+int64(len(line.text))]; got != line.text {
+			t.Errorf("line %d: content at offset %d is %q, want %q", line.number, line.offset, got, line.text)
+		}
+	}
+	if number != 3 {
+		t.Errorf("got %d lines, want 3", number)
+	}
+}
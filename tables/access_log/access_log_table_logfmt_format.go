@@ -0,0 +1,83 @@
+package access_log
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const AccessLogLogfmtFormatIdentifier = "apache_access_log_logfmt"
+
+// AccessLogLogfmtFormat is the format of access logs written as key=value pairs (logfmt), using a LogFormat such as
+// "ts=%{%Y-%m-%dT%H:%M:%S%z}t ip=%a method=%m uri=\"%U\" status=%>s dur=%D"
+//
+// The pairs may be logged in any order and any key may be omitted. Keys with the same name as a column are mapped
+// to that column, any other keys are mapped using Fields.
+type AccessLogLogfmtFormat struct {
+	AccessLogOptions
+	// required to allow partial decoding, as the options are decoded from the same body
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the column each key is mapped to, keyed by key
+	Fields map[string]string `hcl:"fields,optional"`
+}
+
+func NewAccessLogLogfmtFormat() formats.Format {
+	return &AccessLogLogfmtFormat{}
+}
+
+func (a *AccessLogLogfmtFormat) Validate() error {
+	if err := a.validateOptions(a.Remain); err != nil {
+		return err
+	}
+	return validateKeyedFields(a.Fields)
+}
+
+// Identifier returns the format TYPE
+func (a *AccessLogLogfmtFormat) Identifier() string {
+	return AccessLogLogfmtFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *AccessLogLogfmtFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *AccessLogLogfmtFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *AccessLogLogfmtFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *AccessLogLogfmtFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &accessLogKeyValueMapper{
+		identifier:     "apache_access_log_logfmt_mapper",
+		decode:         decodeLogfmtLine,
+		fields:         a.Fields,
+		columns:        keyedFormatColumns(),
+		includeRawLine: a.includesRawLine(),
+		keepUnparsed:   a.keepsUnparsedLines(),
+	}, nil
+}
+
+// GetRegex returns N/A, as logfmt lines are not parsed with a regex
+func (a *AccessLogLogfmtFormat) GetRegex() (string, error) {
+	return "N/A", nil
+}
+
+func (a *AccessLogLogfmtFormat) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	for key, column := range a.Fields {
+		properties["fields."+key] = column
+	}
+	a.addProperties(properties)
+	return properties
+}
@@ -0,0 +1,133 @@
+package access_log
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_AccessLogLogfmtFormat_GetMapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  *AccessLogLogfmtFormat
+		logLine string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Short key names",
+			format: &AccessLogLogfmtFormat{
+				Fields: map[string]string{"ts": "timestamp", "ip": "remote_addr", "method": "request_method", "uri": "request_uri", "dur": "request_time_us"},
+			},
+			logLine: `ts=2025-02-24T12:34:56+0000 ip=192.168.1.1 method=GET uri="/search results/index.html" status=200 dur=1532`,
+			want: map[string]string{
+				"timestamp":       "2025-02-24T12:34:56+00:00",
+				"remote_addr":     "192.168.1.1",
+				"request_method":  "GET",
+				"request_uri":     "/search results/index.html",
+				"status":          "200",
+				"request_time_us": "1532",
+			},
+		},
+		{
+			name: "Reordered and omitted keys",
+			format: &AccessLogLogfmtFormat{
+				Fields: map[string]string{"ts": "timestamp", "ip": "remote_addr", "method": "request_method", "uri": "request_uri", "dur": "request_time_us"},
+			},
+			logLine: `status=404   uri="/missing" ip=10.0.0.1 extra=ignored`,
+			want: map[string]string{
+				"remote_addr": "10.0.0.1",
+				"request_uri": "/missing",
+				"status":      "404",
+			},
+		},
+		{
+			name:    "Escaped quoted values and request line",
+			format:  &AccessLogLogfmtFormat{},
+			logLine: `request_line="\x16\x03\x01" http_user_agent="curl \"8.5.0\" \\ path" http_referer=- `,
+			want: map[string]string{
+				"request_line":         "\x16\x03\x01",
+				"is_malformed_request": "true",
+				"http_user_agent":      `curl "8.5.0" \ path`,
+				"http_referer":         "-",
+			},
+		},
+		{
+			name:    "Unterminated quoted value",
+			format:  &AccessLogLogfmtFormat{},
+			logLine: `status=200 request_uri="/index.html`,
+			wantErr: true,
+		},
+		{
+			name:    "Not logfmt",
+			format:  &AccessLogLogfmtFormat{},
+			logLine: `=200`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := tt.format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			if tt.wantErr {
+				t.Fatalf("expected error, got row %v", row)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+			for _, k := range []string{"extra", "request_time_us", "timestamp"} {
+				if _, ok := tt.want[k]; !ok {
+					if got, ok := row.GetSourceValue(k); ok {
+						t.Errorf("%s: got %q, want missing", k, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_AccessLogLogfmtFormatPresets(t *testing.T) {
+	format, ok := AccessLogLogfmtFormatPresets[0].(*AccessLogLogfmtFormat)
+	if !ok {
+		t.Fatalf("expected preset to be an AccessLogLogfmtFormat")
+	}
+	if err := format.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	mapper, err := format.GetMapper()
+	if err != nil {
+		t.Fatalf("failed to get mapper: %v", err)
+	}
+	row, err := mapper.Map(context.Background(), `ts=2025-02-24T12:34:56-0500 ip=203.0.113.42 method=POST uri="/api/orders" query="?dry_run=1" status=201 bytes=348 dur=20412 ua="Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0"`)
+	if err != nil {
+		t.Fatalf("unexpected mapping error: %v", err)
+	}
+	want := map[string]string{
+		"timestamp":       "2025-02-24T12:34:56-05:00",
+		"remote_addr":     "203.0.113.42",
+		"request_method":  "POST",
+		"request_uri":     "/api/orders",
+		"query_string":    "?dry_run=1",
+		"status":          "201",
+		"bytes_sent":      "348",
+		"request_time_us": "20412",
+		"http_user_agent": "Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0",
+	}
+	for k, v := range want {
+		if got, _ := row.GetSourceValue(k); got != v {
+			t.Errorf("%s: got %q, want %q", k, got, v)
+		}
+	}
+}
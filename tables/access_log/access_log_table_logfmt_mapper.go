@@ -0,0 +1,96 @@
+package access_log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeLogfmtLine decodes a line of space separated key=value pairs to its keys and values
+//
+// Values containing spaces are quoted, in which case Apache escapes '"' and '\' with a backslash, and non-printable
+// characters as \xhh (or \n, \t etc.). A key with no value is set to "true", as in other logfmt implementations.
+func decodeLogfmtLine(input string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i := 0; i < len(input); {
+		// skip the spaces between pairs
+		if input[i] == ' ' || input[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(input) && input[i] != '=' && input[i] != ' ' && input[i] != '\t' {
+			i++
+		}
+		key := input[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("invalid logfmt: missing key at position %d", start)
+		}
+		if i == len(input) || input[i] != '=' {
+			values[key] = "true"
+			continue
+		}
+		// skip the '='
+		i++
+
+		if i < len(input) && input[i] == '"' {
+			value, end, err := unquoteLogfmtValue(input, i)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+			i = end
+			continue
+		}
+		start = i
+		for i < len(input) && input[i] != ' ' && input[i] != '\t' {
+			i++
+		}
+		values[key] = input[start:i]
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("invalid logfmt: no key=value pairs")
+	}
+	return values, nil
+}
+
+// unquoteLogfmtValue unquotes the quoted value starting at position start, returning the value and the position
+// following the closing quote
+func unquoteLogfmtValue(input string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(input) {
+				break
+			}
+			i++
+			switch input[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'x':
+				if i+2 < len(input) {
+					if c, err := strconv.ParseUint(input[i+1:i+3], 16, 8); err == nil {
+						b.WriteByte(byte(c))
+						i += 2
+						continue
+					}
+				}
+				b.WriteString(`\x`)
+			default:
+				b.WriteByte(input[i])
+			}
+		default:
+			b.WriteByte(input[i])
+		}
+	}
+	return "", 0, fmt.Errorf("invalid logfmt: unterminated quoted value at position %d", start)
+}
@@ -0,0 +1,100 @@
+package access_log
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// accessLogMapper maps a log line to a row using a regex, then applies access log specific parsing
+// to the matched fields (e.g. splitting the request line)
+type accessLogMapper struct {
+	re *regexp.Regexp
+	// if set, the original log line is included in the raw_line field
+	includeRawLine bool
+	// if set, lines which do not match the regex are mapped to an unparsed row rather than returning an error
+	keepUnparsed bool
+	// the column type of each field - if set (in lenient parse mode), fields which cannot be converted to
+	// their column type are set to null rather than causing the row to be dropped
+	fieldTypes map[string]string
+	// the named values captured by the layout which are collected into json columns (e.g. request headers)
+	namedValues []namedValue
+	// the strftime formats of the timestamp parts, if the timestamp is logged by several directives
+	timestampFormats []string
+}
+
+func newAccessLogMapper(pattern string) (*accessLogMapper, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling regex pattern: %w", err)
+	}
+	return &accessLogMapper{re: re}, nil
+}
+
+func (m *accessLogMapper) Identifier() string {
+	return "apache_access_log_mapper"
+}
+
+// Map maps a log line, which is either a string or an accessLogLine read by the accessLogLoader
+func (m *accessLogMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var input string
+	var lineNumber, lineOffset int64
+	switch line := a.(type) {
+	case string:
+		input = line
+	case *accessLogLine:
+		input = line.text
+		lineNumber = line.number
+		lineOffset = line.offset
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields := make(map[string]string)
+	match := m.re.FindStringSubmatch(input)
+	switch {
+	case match != nil:
+		for i, name := range m.re.SubexpNames() {
+			// skip index 0, which is the full match
+			if i != 0 && name != "" {
+				fields[name] = match[i]
+			}
+		}
+		applyRequestLine(fields)
+		applyTimestampParts(fields, m.timestampFormats)
+		applyPeerAddr(fields)
+		applyNamedValues(fields, m.namedValues)
+		if m.fieldTypes != nil {
+			validateFieldTypes(fields, m.fieldTypes)
+		}
+		if m.includeRawLine {
+			fields["raw_line"] = input
+		}
+		if m.keepUnparsed {
+			fields["is_unparsed"] = "false"
+		}
+	case m.keepUnparsed:
+		// the raw line is always included for unparsed rows, as it is the only record of the line
+		fields["raw_line"] = input
+		fields["is_unparsed"] = "true"
+		addParseError(fields, "line", "does not match the layout")
+	default:
+		return nil, fmt.Errorf("error parsing log line: failed to match regex pattern %s", m.re.String())
+	}
+
+	// provenance of lines read by the accessLogLoader
+	if lineNumber > 0 {
+		fields["log_line"] = strconv.FormatInt(lineNumber, 10)
+		fields["log_offset"] = strconv.FormatInt(lineOffset, 10)
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
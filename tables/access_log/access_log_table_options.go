@@ -0,0 +1,181 @@
+package access_log
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// AccessLogOptions are the options shared by all the access log formats (apache_access_log, apache_access_log_json,
+// apache_access_log_logfmt and apache_access_log_tomcat), which control how rows are enriched by the table
+type AccessLogOptions struct {
+	// required to allow partial decoding, as the options are decoded from the body of the format
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the parsed field used to populate tp_index (server_name, server_port or http_host)
+	TpIndexField *string `hcl:"tp_index_field,optional"`
+	// include the original log line in the raw_line column
+	IncludeRawLine *bool `hcl:"include_raw_line,optional"`
+	// how fields which cannot be parsed are handled (strict or lenient)
+	ParseMode *string `hcl:"parse_mode,optional"`
+	// the tp_timestamp of lines which do not log the time (collection_time)
+	TimestampFallback *string `hcl:"timestamp_fallback,optional"`
+	// keep lines which cannot be parsed as unparsed rows, rather than dropping them
+	KeepUnparsedLines *bool `hcl:"keep_unparsed_lines,optional"`
+
+	// client IP anonymization mode (truncate or hmac)
+	IpAnonymization *string `hcl:"ip_anonymization,optional"`
+	// the prefix lengths to retain when truncating IP addresses
+	Ipv4PrefixLength *int `hcl:"ipv4_prefix_length,optional"`
+	Ipv6PrefixLength *int `hcl:"ipv6_prefix_length,optional"`
+	// hash the remote user
+	HashUsernames *bool `hcl:"hash_usernames,optional"`
+	// the local key used to HMAC IP addresses and usernames
+	AnonymizationKey *string `hcl:"anonymization_key,optional"`
+
+	// query parameters whose values are redacted from the request URI, query string and referer
+	RedactQueryParameters []string `hcl:"redact_query_parameters,optional"`
+	// regular expressions whose matches are redacted
+	RedactPatterns []string `hcl:"redact_patterns,optional"`
+	// built-in detectors to apply (email, jwt, api_key, credit_card)
+	RedactDetectors []string `hcl:"redact_detectors,optional"`
+
+	// threat intel lists to match client addresses against, keyed by list name (values are paths to text or CSV files)
+	ThreatIntelLists map[string]string `hcl:"threat_intel_lists,optional"`
+
+	// the inactivity period after which a visitor session ends (e.g. 30m) - setting this enables sessionisation
+	SessionTimeout *string `hcl:"session_timeout,optional"`
+	// the cookie used to identify visitors (only supported by apache_access_log formats, which must capture it in
+	// the layout with %{name}C)
+	SessionCookie *string `hcl:"session_cookie,optional"`
+}
+
+// tpIndexFields are the parsed fields which may be used to populate tp_index
+var tpIndexFields = []string{"server_name", "server_port", "http_host"}
+
+// getAccessLogOptions returns the options - it is promoted to each format which embeds the options, so the table
+// can find the options of any access log format
+func (a *AccessLogOptions) getAccessLogOptions() *AccessLogOptions {
+	return a
+}
+
+// validate validates the options
+func (a *AccessLogOptions) validate() error {
+	if a.TpIndexField != nil && !slices.Contains(tpIndexFields, *a.TpIndexField) {
+		return fmt.Errorf("invalid tp_index_field '%s': must be one of %s", *a.TpIndexField, strings.Join(tpIndexFields, ", "))
+	}
+	if err := a.validateParseMode(); err != nil {
+		return err
+	}
+	if err := a.validateTimestampFallback(); err != nil {
+		return err
+	}
+	if err := a.validateAnonymization(); err != nil {
+		return err
+	}
+	if err := a.validateRedaction(); err != nil {
+		return err
+	}
+	for name, path := range a.ThreatIntelLists {
+		if name == "" || path == "" {
+			return fmt.Errorf("invalid threat_intel_lists entry '%s': list name and path must be set", name)
+		}
+	}
+	return a.validateSessions()
+}
+
+// validateArguments returns an error for any argument of the format which is neither an option nor an argument of
+// the format itself - as the format and the options each allow the arguments of the other, an argument remaining
+// after both have been decoded is not supported
+func (a *AccessLogOptions) validateArguments(formatRemain hcl.Body) error {
+	if a.Remain == nil || formatRemain == nil {
+		return nil
+	}
+	formatAttributes, _ := formatRemain.JustAttributes()
+	optionAttributes, _ := a.Remain.JustAttributes()
+	for _, name := range slices.Sorted(maps.Keys(formatAttributes)) {
+		if _, ok := optionAttributes[name]; ok {
+			return fmt.Errorf("unsupported argument '%s'", name)
+		}
+	}
+	return nil
+}
+
+// validateOptions validates the arguments and options of the formats other than apache_access_log, which do not
+// support session_cookie, as visitors are identified by a cookie captured by the layout
+func (a *AccessLogOptions) validateOptions(formatRemain hcl.Body) error {
+	if err := a.validateArguments(formatRemain); err != nil {
+		return err
+	}
+	if err := a.validate(); err != nil {
+		return err
+	}
+	if a.SessionCookie != nil {
+		return fmt.Errorf("session_cookie is only supported by %s formats", AccessLogTableIdentifier)
+	}
+	return nil
+}
+
+// includesRawLine returns whether the original log line should be included in the raw_line column
+func (a *AccessLogOptions) includesRawLine() bool {
+	return a.IncludeRawLine != nil && *a.IncludeRawLine
+}
+
+// keepsUnparsedLines returns whether lines which cannot be parsed should be kept as unparsed rows
+func (a *AccessLogOptions) keepsUnparsedLines() bool {
+	return a.KeepUnparsedLines != nil && *a.KeepUnparsedLines
+}
+
+// addProperties adds the options which are set to the properties of the format
+func (a *AccessLogOptions) addProperties(properties map[string]string) {
+	if a.TpIndexField != nil {
+		properties["tp_index_field"] = *a.TpIndexField
+	}
+	if a.IncludeRawLine != nil {
+		properties["include_raw_line"] = strconv.FormatBool(*a.IncludeRawLine)
+	}
+	if a.ParseMode != nil {
+		properties["parse_mode"] = *a.ParseMode
+	}
+	if a.TimestampFallback != nil {
+		properties["timestamp_fallback"] = *a.TimestampFallback
+	}
+	if a.KeepUnparsedLines != nil {
+		properties["keep_unparsed_lines"] = strconv.FormatBool(*a.KeepUnparsedLines)
+	}
+	// NOTE: the anonymization key is deliberately not included
+	if a.IpAnonymization != nil {
+		properties["ip_anonymization"] = *a.IpAnonymization
+	}
+	if a.Ipv4PrefixLength != nil {
+		properties["ipv4_prefix_length"] = strconv.Itoa(*a.Ipv4PrefixLength)
+	}
+	if a.Ipv6PrefixLength != nil {
+		properties["ipv6_prefix_length"] = strconv.Itoa(*a.Ipv6PrefixLength)
+	}
+	if a.HashUsernames != nil {
+		properties["hash_usernames"] = strconv.FormatBool(*a.HashUsernames)
+	}
+	if len(a.RedactQueryParameters) > 0 {
+		properties["redact_query_parameters"] = strings.Join(a.RedactQueryParameters, ",")
+	}
+	if len(a.RedactPatterns) > 0 {
+		properties["redact_patterns"] = strings.Join(a.RedactPatterns, ",")
+	}
+	if len(a.RedactDetectors) > 0 {
+		properties["redact_detectors"] = strings.Join(a.RedactDetectors, ",")
+	}
+	for name, path := range a.ThreatIntelLists {
+		properties["threat_intel_lists."+name] = path
+	}
+	if a.SessionTimeout != nil {
+		properties["session_timeout"] = *a.SessionTimeout
+	}
+	if a.SessionCookie != nil {
+		properties["session_cookie"] = *a.SessionCookie
+	}
+}
@@ -0,0 +1,11 @@
+package access_log
+
+// addParseError records a field which could not be parsed in the parse_error field of a mapped row
+// multiple errors are separated by '; '
+func addParseError(fields map[string]string, field, message string) {
+	parseError := field + ": " + message
+	if existing, ok := fields["parse_error"]; ok && existing != "" {
+		parseError = existing + "; " + parseError
+	}
+	fields["parse_error"] = parseError
+}
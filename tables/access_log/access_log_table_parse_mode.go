@@ -0,0 +1,134 @@
+package access_log
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+)
+
+const (
+	// ParseModeStrict - rows with a field which cannot be parsed are dropped (the default)
+	ParseModeStrict = "strict"
+	// ParseModeLenient - fields which cannot be parsed are set to null, and recorded in parse_error
+	ParseModeLenient = "lenient"
+
+	// TimestampFallbackCollectionTime - lines which do not log the time use the time they are collected as tp_timestamp
+	TimestampFallbackCollectionTime = "collection_time"
+)
+
+// validateParseMode validates the parse mode of the format
+func (a *AccessLogOptions) validateParseMode() error {
+	if a.ParseMode != nil && *a.ParseMode != ParseModeStrict && *a.ParseMode != ParseModeLenient {
+		return fmt.Errorf("invalid parse_mode '%s': must be one of %s, %s", *a.ParseMode, ParseModeStrict, ParseModeLenient)
+	}
+	return nil
+}
+
+// validateTimestampFallback validates the timestamp fallback of the format
+func (a *AccessLogOptions) validateTimestampFallback() error {
+	if a.TimestampFallback != nil && *a.TimestampFallback != TimestampFallbackCollectionTime {
+		return fmt.Errorf("invalid timestamp_fallback '%s': must be %s", *a.TimestampFallback, TimestampFallbackCollectionTime)
+	}
+	return nil
+}
+
+// usesCollectionTime returns whether rows with no timestamp use the collection time as tp_timestamp
+func (a *AccessLogOptions) usesCollectionTime() bool {
+	return a.TimestampFallback != nil && *a.TimestampFallback == TimestampFallbackCollectionTime
+}
+
+// isLenient returns whether the format uses the lenient parse mode
+func (a *AccessLogOptions) isLenient() bool {
+	return a.ParseMode != nil && *a.ParseMode == ParseModeLenient
+}
+
+// getFieldTypes returns the column type for each source field of the schema
+func getFieldTypes(tableSchema *schema.TableSchema) map[string]string {
+	res := make(map[string]string, len(tableSchema.Columns))
+	for _, c := range tableSchema.Columns {
+		source := c.SourceName
+		if source == "" {
+			source = c.ColumnName
+		}
+		// a source field may be mapped to several columns - only record the first type
+		if _, ok := res[source]; !ok {
+			res[source] = c.Type
+		}
+	}
+	return res
+}
+
+// validateFieldTypes checks each mapped field can be converted to the type of its column
+// fields which cannot be converted are set to the nil value and recorded in the parse_error field
+func validateFieldTypes(fields map[string]string, fieldTypes map[string]string) {
+	// check fields in a consistent order, so parse_error is deterministic
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		value := fields[field]
+		if value == "" || value == AccessLogTableNilValue {
+			continue
+		}
+		if err := validateFieldType(value, fieldTypes[field]); err != nil {
+			fields[field] = AccessLogTableNilValue
+			addParseError(fields, field, fmt.Sprintf("invalid value '%s': %s", value, err.Error()))
+		}
+	}
+}
+
+// validateFieldType returns an error if the value cannot be converted to the column type
+func validateFieldType(value, columnType string) error {
+	var err error
+	switch strings.ToLower(columnType) {
+	case "tinyint", "smallint", "integer", "bigint", "hugeint":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "utinyint", "usmallint", "uinteger", "ubigint", "uhugeint":
+		_, err = strconv.ParseUint(value, 10, 64)
+	case "float", "double", "real", "decimal":
+		_, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	case "timestamp", "date", "time":
+		_, err = helpers.ParseTime(value)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("not a valid %s", strings.ToLower(columnType))
+	}
+	return nil
+}
+
+// timestampFallback provides the tp_timestamp for rows with no valid timestamp in lenient mode
+// this is the last valid timestamp seen in the same artifact (so the row is partitioned alongside its neighbours)
+// or, if there is none, the current time
+type timestampFallback struct {
+	lastTimestamps map[string]time.Time
+	mut            sync.Mutex
+}
+
+func newTimestampFallback() *timestampFallback {
+	return &timestampFallback{lastTimestamps: make(map[string]time.Time)}
+}
+
+// update records the last valid timestamp for the artifact
+func (f *timestampFallback) update(sourceLocation string, timestamp time.Time) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.lastTimestamps[sourceLocation] = timestamp
+}
+
+// get returns the fallback timestamp for the artifact
+func (f *timestampFallback) get(sourceLocation string) time.Time {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if t, ok := f.lastTimestamps[sourceLocation]; ok {
+		return t
+	}
+	return time.Now()
+}
@@ -7,19 +7,148 @@ import (
 var DefaultApacheAccessLogFormat = &formats.Regex{
 	Name:        "apache_default",
 	Description: "A default regex format that covers both Apache Common and Combined log formats.",
-	Layout:      `^(?P<remote_addr>[^ ]*) (?P<remote_logname>[^ ]*) (?P<remote_user>[^ ]*) \[(?P<timestamp>[^\]]*)\] "(?P<request_method>\S+)(?: +(?P<request_uri>[^ ]+))?(?: +(?P<server_protocol>\S+))?" (?P<status>[^ ]*) (?P<body_bytes_sent>[^ ]*)(?: "(?P<http_referer>(?:\\.|[^"\\])*)" "(?P<http_user_agent>(?:\\.|[^"\\])*)")?$`,
+	Layout:      `^(?P<remote_addr>[^ ]*) (?P<remote_logname>[^ ]*) (?P<remote_user>[^ ]*) \[(?P<timestamp>[^\]]*)\] "(?P<request_line>(?P<request_method>\S+)(?: +(?P<request_uri>[^ ]+))?(?: +(?P<server_protocol>\S+))?|(?:\\.|[^"\\])*)" (?P<status>[^ ]*) (?P<body_bytes_sent>[^ ]*)(?: "(?P<http_referer>(?:\\.|[^"\\])*)" "(?P<http_user_agent>(?:\\.|[^"\\])*)")?$`,
 }
 
+// the layouts of the Apache Common and Combined Log Formats, which are also used by the presets of distributions and
+// hosting platforms which log in these formats
+const (
+	commonLayout   = `%h %l %u %t "%r" %>s %b`
+	combinedLayout = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`
+)
+
 var AccessLogTableFormatPresets = []formats.Format{
 	DefaultApacheAccessLogFormat,
 	&AccessLogTableFormat{
 		Name:        "common",
 		Description: "Apache Common Log Format.",
-		Layout:      `%h %l %u %t "%r" %>s %b`,
+		Layout:      commonLayout,
 	},
 	&AccessLogTableFormat{
 		Name:        "combined",
 		Description: "Apache Combined Log Format.",
-		Layout:      `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`,
+		Layout:      combinedLayout,
+	},
+	// Debian and Ubuntu (/etc/apache2/apache2.conf) - these log the bytes sent including headers (%O)
+	&AccessLogTableFormat{
+		Name:        "debian_vhost_combined",
+		Description: "Debian/Ubuntu vhost_combined format, used for the default other_vhosts_access.log.",
+		Layout:      `%v:%p %h %l %u %t "%r" %>s %O "%{Referer}i" "%{User-Agent}i"`,
+	},
+	&AccessLogTableFormat{
+		Name:        "debian_combined",
+		Description: "Debian/Ubuntu combined format.",
+		Layout:      `%h %l %u %t "%r" %>s %O "%{Referer}i" "%{User-Agent}i"`,
+	},
+	&AccessLogTableFormat{
+		Name:        "debian_common",
+		Description: "Debian/Ubuntu common format.",
+		Layout:      `%h %l %u %t "%r" %>s %O`,
+	},
+	// the referer and agent formats do not log the time, so tp_timestamp is the collection time
+	&AccessLogTableFormat{
+		Name:             "debian_referer",
+		Description:      "Debian/Ubuntu referer format.",
+		Layout:           `%{Referer}i -> %U`,
+		AccessLogOptions: AccessLogOptions{TimestampFallback: &timestampFallbackCollectionTime},
+	},
+	&AccessLogTableFormat{
+		Name:             "debian_agent",
+		Description:      "Debian/Ubuntu agent format.",
+		Layout:           `%{User-agent}i`,
+		AccessLogOptions: AccessLogOptions{TimestampFallback: &timestampFallbackCollectionTime},
+	},
+	// RHEL, CentOS and Fedora (/etc/httpd/conf/httpd.conf)
+	&AccessLogTableFormat{
+		Name:        "rhel_combinedio",
+		Description: "RHEL/CentOS/Fedora combinedio format, which adds the bytes received and sent (mod_logio) to the combined format.",
+		Layout:      `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i" %I %O`,
+	},
+	// cPanel domain logs (/var/log/apache2/domlogs or /usr/local/apache/domlogs)
+	&AccessLogTableFormat{
+		Name:        "cpanel_domlog",
+		Description: "cPanel per-domain access log (domlog) format, an alias of combined.",
+		Layout:      combinedLayout,
+	},
+	// Plesk (/var/www/vhosts/system/<domain>/logs/access_log and access_ssl_log)
+	&AccessLogTableFormat{
+		Name:        "plesk",
+		Description: "Plesk plesklog format, used for the per-domain access_log and access_ssl_log.",
+		Layout:      `%h %l %u %t "%r" %>s %O "%{Referer}i" "%{User-Agent}i"`,
+	},
+	// Bitnami (/opt/bitnami/apache/logs/access_log)
+	&AccessLogTableFormat{
+		Name:        "bitnami",
+		Description: "Bitnami Apache stack and container format, an alias of common.",
+		Layout:      commonLayout,
+	},
+	// the official httpd Docker image logs to stdout using the common format
+	&AccessLogTableFormat{
+		Name:        "httpd_docker",
+		Description: "Official httpd Docker image format, an alias of common.",
+		Layout:      commonLayout,
+	},
+}
+
+var timestampFallbackCollectionTime = TimestampFallbackCollectionTime
+
+var AccessLogJsonFormatPresets = []formats.Format{
+	// the widely used template from the Loggly Apache JSON logging guide:
+	// LogFormat "{ \"time\":\"%t\", \"remoteIP\":\"%a\", \"host\":\"%V\", \"request\":\"%U\", \"query\":\"%q\", \"method\":\"%m\", \"status\":\"%>s\", \"userAgent\":\"%{User-agent}i\", \"referer\":\"%{Referer}i\" }"
+	&AccessLogJsonFormat{
+		Name:        "loggly",
+		Description: "Loggly Apache JSON log template.",
+		Fields: map[string]string{
+			"time":      "timestamp",
+			"remoteIP":  "remote_addr",
+			"host":      "server_name",
+			"request":   "request_uri",
+			"query":     "query_string",
+			"method":    "request_method",
+			"userAgent": "http_user_agent",
+			"referer":   "http_referer",
+		},
+	},
+	// the combined format logged as JSON, with the keys named after the table columns:
+	// LogFormat "{ \"time\":\"%{%Y-%m-%dT%H:%M:%S%z}t\", \"remote_addr\":\"%a\", \"remote_user\":\"%u\", \"request\":\"%r\", \"status\":\"%>s\", \"bytes_sent\":\"%O\", \"http_referer\":\"%{Referer}i\", \"http_user_agent\":\"%{User-Agent}i\", \"request_time_us\":\"%D\" }"
+	&AccessLogJsonFormat{
+		Name:        "combined",
+		Description: "Apache Combined Log Format fields logged as JSON, keyed by column name.",
+		Fields: map[string]string{
+			"time":    "timestamp",
+			"request": "request_line",
+		},
+	},
+}
+
+var AccessLogLogfmtFormatPresets = []formats.Format{
+	// LogFormat "ts=%{%Y-%m-%dT%H:%M:%S%z}t ip=%a method=%m uri=\"%U\" query=\"%q\" status=%>s bytes=%O dur=%D ua=\"%{User-Agent}i\""
+	&AccessLogLogfmtFormat{
+		Name:        "default",
+		Description: "Access log logged as logfmt key=value pairs, with short key names.",
+		Fields: map[string]string{
+			"ts":     "timestamp",
+			"ip":     "remote_addr",
+			"method": "request_method",
+			"uri":    "request_uri",
+			"query":  "query_string",
+			"bytes":  "bytes_sent",
+			"dur":    "request_time_us",
+			"ua":     "http_user_agent",
+		},
+	},
+}
+
+var AccessLogTomcatFormatPresets = []formats.Format{
+	// the pattern of the AccessLogValve in the default server.xml (localhost_access_log.*.txt)
+	&AccessLogTomcatFormat{
+		Name:        "common",
+		Description: "Tomcat AccessLogValve common pattern.",
+		Pattern:     "common",
+	},
+	&AccessLogTomcatFormat{
+		Name:        "combined",
+		Description: "Tomcat AccessLogValve combined pattern.",
+		Pattern:     "combined",
 	},
 }
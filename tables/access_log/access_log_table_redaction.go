@@ -0,0 +1,174 @@
+package access_log
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactedFields are the source fields which redaction rules are applied to
+var redactedFields = []string{"request_uri", "query_string", "http_referer"}
+
+// redactionDetectors are the built-in detectors which may be enabled with redact_detectors
+var redactionDetectors = map[string]*regexp.Regexp{
+	// email addresses, allowing for a url encoded '@'
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+(?:@|%40)[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	// JSON web tokens (header and payload are base64url encoded JSON objects)
+	"jwt": regexp.MustCompile(`eyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`),
+	// well known API key formats (AWS access keys, GitHub, Slack, Stripe and Google API keys)
+	"api_key": regexp.MustCompile(`(?:AKIA|ASIA)[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36}|xox[abposr]-[A-Za-z0-9-]{10,}|[rs]k_live_[A-Za-z0-9]{24,}|AIza[0-9A-Za-z_-]{35}`),
+	// 13-19 digit card numbers, optionally separated by spaces or dashes - matches are Luhn checked
+	"credit_card": regexp.MustCompile(`\d(?:(?:[ -]|%20)?\d){12,18}`),
+}
+
+// redactor applies the redaction rules configured for a format
+type redactor struct {
+	queryParameters map[string]struct{}
+	patterns        []*regexp.Regexp
+	detectors       []string
+}
+
+// validateRedaction validates the redaction options of the format
+func (a *AccessLogOptions) validateRedaction() error {
+	for _, p := range a.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid redact_patterns entry '%s': %w", p, err)
+		}
+	}
+	for _, d := range a.RedactDetectors {
+		if _, ok := redactionDetectors[d]; !ok {
+			return fmt.Errorf("invalid redact_detectors entry '%s': must be one of email, jwt, api_key, credit_card", d)
+		}
+	}
+	return nil
+}
+
+// newRedactor builds a redactor from the format redaction options
+// if no redaction is configured, nil is returned
+func newRedactor(format *AccessLogOptions) (*redactor, error) {
+	if len(format.RedactQueryParameters) == 0 && len(format.RedactPatterns) == 0 && len(format.RedactDetectors) == 0 {
+		return nil, nil
+	}
+	if err := format.validateRedaction(); err != nil {
+		return nil, err
+	}
+
+	r := &redactor{
+		queryParameters: make(map[string]struct{}, len(format.RedactQueryParameters)),
+		detectors:       format.RedactDetectors,
+	}
+	for _, p := range format.RedactQueryParameters {
+		r.queryParameters[strings.ToLower(p)] = struct{}{}
+	}
+	for _, p := range format.RedactPatterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+	return r, nil
+}
+
+// redact applies the redaction rules to the value, returning the redacted value and whether anything was redacted
+func (r *redactor) redact(value string) (string, bool) {
+	return r.redactContent(r.redactQueryParameters(value), value)
+}
+
+// redactRequestLine redacts a request line, given the original and redacted request URI
+// if the request line could not be parsed (so has no URI) the patterns and detectors are applied to the whole line
+func (r *redactor) redactRequestLine(line, uri, redactedUri string) (string, bool) {
+	if uri == "" {
+		return r.redactContent(line, line)
+	}
+	res := strings.Replace(line, uri, redactedUri, 1)
+	return res, res != line
+}
+
+// redactContent applies the patterns and detectors to the value, returning the redacted value and whether it
+// differs from the original value
+func (r *redactor) redactContent(value, original string) (string, bool) {
+	res := value
+	for _, p := range r.patterns {
+		res = p.ReplaceAllString(res, redactedValue)
+	}
+
+	for _, name := range r.detectors {
+		d := redactionDetectors[name]
+		if name == "credit_card" {
+			res = d.ReplaceAllStringFunc(res, func(match string) string {
+				if luhnValid(match) {
+					return redactedValue
+				}
+				return match
+			})
+			continue
+		}
+		res = d.ReplaceAllString(res, redactedValue)
+	}
+
+	return res, res != original
+}
+
+// redactQueryParameters replaces the values of any configured query parameters
+// value may be a full URL, a request URI or a query string (with or without the leading '?')
+func (r *redactor) redactQueryParameters(value string) string {
+	if len(r.queryParameters) == 0 {
+		return value
+	}
+
+	prefix, query := "", value
+	if i := strings.IndexByte(value, '?'); i >= 0 {
+		prefix, query = value[:i+1], value[i+1:]
+	} else if strings.HasPrefix(value, "/") || strings.Contains(value, "://") {
+		// a path or URL with no query string
+		return value
+	}
+
+	// retain any fragment
+	fragment := ""
+	if i := strings.IndexByte(query, '#'); i >= 0 {
+		query, fragment = query[:i], query[i:]
+	}
+
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		name, _, hasValue := strings.Cut(param, "=")
+		if !hasValue {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if _, ok := r.queryParameters[strings.ToLower(name)]; ok {
+			params[i] = param[:strings.IndexByte(param, '=')+1] + redactedValue
+		}
+	}
+
+	return prefix + strings.Join(params, "&") + fragment
+}
+
+// luhnValid returns whether the digits in the value pass the Luhn checksum
+func luhnValid(value string) bool {
+	var digits []int
+	for _, c := range strings.ReplaceAll(value, "%20", "") {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,137 @@
+package access_log
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// request target forms (RFC 9112 section 3.2)
+const (
+	RequestTargetFormOrigin    = "origin"
+	RequestTargetFormAbsolute  = "absolute"
+	RequestTargetFormAuthority = "authority"
+	RequestTargetFormAsterisk  = "asterisk"
+)
+
+var (
+	// the characters permitted in an HTTP method (an RFC 9110 token)
+	requestMethodRegex = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+	// HTTP/0.9 to HTTP/3 protocol versions, as logged by Apache (e.g. HTTP/1.1, HTTP/2.0)
+	requestProtocolRegex = regexp.MustCompile(`^HTTP/\d(?:\.\d)?$`)
+)
+
+// requestLine is a request line parsed into its parts
+type requestLine struct {
+	method     string
+	target     string
+	protocol   string
+	targetForm string
+	host       string
+	malformed  bool
+}
+
+// parseRequestLine parses a request line as logged by %r
+//
+// Unlike the layout regex this handles request targets containing spaces, absolute-form targets sent to proxies,
+// CONNECT authority-form targets and asterisk-form OPTIONS requests. Lines which cannot be parsed as a request
+// (e.g. a TLS handshake sent to a plain HTTP port, which Apache logs as "\x16\x03\x01...") are marked as malformed
+// and have no method, target or protocol.
+// An empty or '-' line (logged by Apache when no request was received, e.g. for a 408 response) returns nil.
+func parseRequestLine(line string) *requestLine {
+	line = strings.TrimSpace(line)
+	if line == "" || line == AccessLogTableNilValue {
+		return nil
+	}
+
+	fields := strings.Fields(line)
+	res := &requestLine{method: fields[0]}
+	if !requestMethodRegex.MatchString(res.method) {
+		return &requestLine{malformed: true}
+	}
+
+	switch {
+	case len(fields) == 1:
+		return &requestLine{malformed: true}
+	case len(fields) == 2:
+		// HTTP/0.9 simple request, with no protocol
+		res.target = fields[1]
+	default:
+		last := fields[len(fields)-1]
+		if !requestProtocolRegex.MatchString(last) {
+			return &requestLine{malformed: true}
+		}
+		res.protocol = last
+		// the target is everything between the method and protocol, and may contain spaces
+		res.target = strings.TrimSpace(line[len(res.method) : len(line)-len(last)])
+	}
+
+	res.targetForm, res.host = classifyRequestTarget(res.method, res.target)
+	res.malformed = res.targetForm == ""
+	return res
+}
+
+// classifyRequestTarget returns the form of the request target and, for absolute and authority form targets, the target host
+// if the target is not a valid form for the method, an empty form is returned
+func classifyRequestTarget(method, target string) (string, string) {
+	if strings.EqualFold(method, "CONNECT") {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil || host == "" || port == "" {
+			return "", ""
+		}
+		return RequestTargetFormAuthority, strings.ToLower(host)
+	}
+
+	switch {
+	case strings.HasPrefix(target, "/"):
+		return RequestTargetFormOrigin, ""
+	case target == "*" && strings.EqualFold(method, "OPTIONS"):
+		return RequestTargetFormAsterisk, ""
+	case strings.Contains(target, "://"):
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "", ""
+		}
+		return RequestTargetFormAbsolute, strings.ToLower(u.Hostname())
+	}
+	return "", ""
+}
+
+// applyRequestLine replaces the request_method, request_uri and server_protocol fields of a mapped row with the values
+// parsed from request_line, and adds the request target form, host and malformed fields
+func applyRequestLine(fields map[string]string) {
+	line, ok := fields["request_line"]
+	if !ok {
+		return
+	}
+
+	parsed := parseRequestLine(line)
+	if parsed == nil {
+		return
+	}
+
+	fields["is_malformed_request"] = "false"
+	if parsed.malformed {
+		fields["is_malformed_request"] = "true"
+		fields["request_method"] = AccessLogTableNilValue
+		fields["request_uri"] = AccessLogTableNilValue
+		fields["server_protocol"] = AccessLogTableNilValue
+		addParseError(fields, "request_line", "not a valid HTTP request line")
+		return
+	}
+
+	fields["request_method"] = parsed.method
+	fields["request_uri"] = parsed.target
+	fields["server_protocol"] = valueOrNil(parsed.protocol)
+	fields["request_target_form"] = parsed.targetForm
+	fields["request_host"] = valueOrNil(parsed.host)
+}
+
+// valueOrNil returns the value, or the nil value if it is empty
+func valueOrNil(value string) string {
+	if value == "" {
+		return AccessLogTableNilValue
+	}
+	return value
+}
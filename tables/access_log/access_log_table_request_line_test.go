@@ -0,0 +1,152 @@
+package access_log
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_parseRequestLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want *requestLine
+	}{
+		{
+			name: "origin form",
+			line: "GET /index.html?page=2 HTTP/1.1",
+			want: &requestLine{method: "GET", target: "/index.html?page=2", protocol: "HTTP/1.1", targetForm: RequestTargetFormOrigin},
+		},
+		{
+			name: "http/2",
+			line: "POST /api/v1/items HTTP/2.0",
+			want: &requestLine{method: "POST", target: "/api/v1/items", protocol: "HTTP/2.0", targetForm: RequestTargetFormOrigin},
+		},
+		{
+			name: "http/3",
+			line: "GET / HTTP/3",
+			want: &requestLine{method: "GET", target: "/", protocol: "HTTP/3", targetForm: RequestTargetFormOrigin},
+		},
+		{
+			name: "target containing spaces",
+			line: "GET /my documents/report 2025.pdf HTTP/1.1",
+			want: &requestLine{method: "GET", target: "/my documents/report 2025.pdf", protocol: "HTTP/1.1", targetForm: RequestTargetFormOrigin},
+		},
+		{
+			name: "absolute form from a proxy client",
+			line: "GET http://WWW.Example.com:8080/path?q=1 HTTP/1.1",
+			want: &requestLine{method: "GET", target: "http://WWW.Example.com:8080/path?q=1", protocol: "HTTP/1.1", targetForm: RequestTargetFormAbsolute, host: "www.example.com"},
+		},
+		{
+			name: "connect authority form",
+			line: "CONNECT example.com:443 HTTP/1.1",
+			want: &requestLine{method: "CONNECT", target: "example.com:443", protocol: "HTTP/1.1", targetForm: RequestTargetFormAuthority, host: "example.com"},
+		},
+		{
+			name: "options asterisk form",
+			line: "OPTIONS * HTTP/1.1",
+			want: &requestLine{method: "OPTIONS", target: "*", protocol: "HTTP/1.1", targetForm: RequestTargetFormAsterisk},
+		},
+		{
+			name: "http/0.9 simple request",
+			line: "GET /",
+			want: &requestLine{method: "GET", target: "/", targetForm: RequestTargetFormOrigin},
+		},
+		{
+			name: "tls handshake on plain http port",
+			line: `\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03`,
+			want: &requestLine{malformed: true},
+		},
+		{
+			name: "connect without port",
+			line: "CONNECT example.com HTTP/1.1",
+			want: &requestLine{method: "CONNECT", target: "example.com", protocol: "HTTP/1.1", malformed: true},
+		},
+		{
+			name: "relative target",
+			line: "GET index.html HTTP/1.1",
+			want: &requestLine{method: "GET", target: "index.html", protocol: "HTTP/1.1", malformed: true},
+		},
+		{
+			name: "invalid protocol",
+			line: "GET / FOO/1.0",
+			want: &requestLine{malformed: true},
+		},
+		{
+			name: "no request",
+			line: "-",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRequestLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRequestLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_accessLogMapper_Map(t *testing.T) {
+	format := &AccessLogTableFormat{Name: "test", Layout: `%h %l %u %t "%r" %>s %b`}
+	mapper, err := format.GetMapper()
+	if err != nil {
+		t.Fatalf("failed to get mapper: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		logLine string
+		want    map[string]string
+	}{
+		{
+			name:    "target containing spaces",
+			logLine: `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "GET /my file.html HTTP/1.1" 404 209`,
+			want: map[string]string{
+				"request_line":         "GET /my file.html HTTP/1.1",
+				"request_method":       "GET",
+				"request_uri":          "/my file.html",
+				"server_protocol":      "HTTP/1.1",
+				"request_target_form":  RequestTargetFormOrigin,
+				"is_malformed_request": "false",
+				"status":               "404",
+			},
+		},
+		{
+			name:    "tls handshake",
+			logLine: `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03" 400 226`,
+			want: map[string]string{
+				"request_line":         `\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03`,
+				"request_method":       "-",
+				"request_uri":          "-",
+				"server_protocol":      "-",
+				"is_malformed_request": "true",
+				"status":               "400",
+			},
+		},
+		{
+			name:    "request timeout",
+			logLine: `192.168.1.1 - - [24/Feb/2025:12:34:56 +0000] "-" 408 -`,
+			want: map[string]string{
+				"request_line":   "-",
+				"request_method": "-",
+				"status":         "408",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package access_log
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionTimeout = 30 * time.Minute
+	// the number of rows processed for an artifact between evictions of expired sessions
+	sessionEvictionInterval = 10000
+	// the time since an artifact last had a row, after which collection of the artifact is assumed to have finished
+	// and its sessions are removed
+	artifactIdleTimeout = 10 * time.Minute
+)
+
+// invalidGroupNameChars matches characters which are not valid in a regex group name
+var invalidGroupNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// cookieGroupName returns the regex group name used for a cookie captured with %{name}C
+func cookieGroupName(name string) string {
+	return "cookie_" + invalidGroupNameChars.ReplaceAllString(name, "_")
+}
+
+// validateSessions validates the sessionisation options of the format
+func (a *AccessLogOptions) validateSessions() error {
+	if a.SessionTimeout != nil {
+		timeout, err := time.ParseDuration(*a.SessionTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid session_timeout '%s': %w", *a.SessionTimeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("invalid session_timeout '%s': must be greater than zero", *a.SessionTimeout)
+		}
+	}
+	if a.SessionCookie != nil && *a.SessionCookie == "" {
+		return fmt.Errorf("session_cookie must not be empty")
+	}
+	return nil
+}
+
+// validateSessionCookie validates the session cookie is captured by the layout
+func (a *AccessLogTableFormat) validateSessionCookie() error {
+	if a.SessionCookie != nil && !regexp.MustCompile(`%\{`+regexp.QuoteMeta(*a.SessionCookie)+`\}C`).MatchString(a.Layout) {
+		return fmt.Errorf("session_cookie '%s' is not captured by the layout: add %%{%s}C to the layout", *a.SessionCookie, *a.SessionCookie)
+	}
+	return nil
+}
+
+// sessionTracker assigns requests to visitor sessions
+// a visitor is identified by the configured session cookie or, if the cookie is not set, by client address and user agent,
+// and a session ends after a period of inactivity - sessions are tracked independently for each artifact
+type sessionTracker struct {
+	format  *AccessLogOptions
+	timeout time.Duration
+	// returns the current time, used to find the artifacts which are no longer being collected
+	now func() time.Time
+
+	// the active sessions for each artifact, keyed by source location then visitor key
+	artifacts map[string]*artifactSessions
+	mut       sync.Mutex
+}
+
+type artifactSessions struct {
+	sessions map[string]*visitorSession
+	rows     int
+	// the time the artifact last had a row
+	lastUsed time.Time
+}
+
+type visitorSession struct {
+	id       string
+	lastSeen time.Time
+}
+
+// newSessionTracker builds a sessionTracker from the format sessionisation options
+// if sessionisation is not configured, nil is returned
+func newSessionTracker(format *AccessLogOptions) (*sessionTracker, error) {
+	if format.SessionTimeout == nil && format.SessionCookie == nil {
+		return nil, nil
+	}
+	if err := format.validateSessions(); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultSessionTimeout
+	if format.SessionTimeout != nil {
+		// already validated
+		timeout, _ = time.ParseDuration(*format.SessionTimeout)
+	}
+	return &sessionTracker{
+		format:    format,
+		timeout:   timeout,
+		now:       time.Now,
+		artifacts: make(map[string]*artifactSessions),
+	}, nil
+}
+
+// sessionId returns the id of the session the request belongs to, starting a new session if the visitor
+// has no session in the artifact or their previous request was longer ago than the session timeout
+func (s *sessionTracker) sessionId(sourceLocation, visitor string, timestamp time.Time) string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	now := s.now()
+	artifact, ok := s.artifacts[sourceLocation]
+	if !ok {
+		// the rows of an artifact are not followed by any notification the artifact is complete, so the sessions of
+		// artifacts with no recent rows are removed when a new artifact is started
+		s.removeIdleArtifacts(now)
+		artifact = &artifactSessions{sessions: make(map[string]*visitorSession)}
+		s.artifacts[sourceLocation] = artifact
+	}
+	artifact.lastUsed = now
+
+	session, ok := artifact.sessions[visitor]
+	if !ok || timestamp.Sub(session.lastSeen) > s.timeout {
+		// the id is derived from the artifact, visitor and session start so re-collecting an artifact gives the same ids
+		// (it is hashed with the anonymization key if one is set - otherwise truncated addresses identify the visitor,
+		// so the visitor cannot be recovered from it)
+		start := strconv.FormatInt(timestamp.UnixNano(), 10)
+		session = &visitorSession{id: s.format.hash(sourceLocation + "\x00" + visitor + "\x00" + start)}
+		artifact.sessions[visitor] = session
+	}
+	// rows are not guaranteed to be strictly ordered, so never move lastSeen backwards
+	if timestamp.After(session.lastSeen) {
+		session.lastSeen = timestamp
+	}
+
+	// periodically remove expired sessions to bound memory usage for large artifacts
+	artifact.rows++
+	if artifact.rows%sessionEvictionInterval == 0 {
+		for k, v := range artifact.sessions {
+			if timestamp.Sub(v.lastSeen) > s.timeout {
+				delete(artifact.sessions, k)
+			}
+		}
+	}
+
+	return session.id
+}
+
+// removeIdleArtifacts removes the sessions of any artifacts which have had no rows for the artifact idle timeout
+func (s *sessionTracker) removeIdleArtifacts(now time.Time) {
+	for sourceLocation, artifact := range s.artifacts {
+		if now.Sub(artifact.lastUsed) > artifactIdleTimeout {
+			delete(s.artifacts, sourceLocation)
+		}
+	}
+}
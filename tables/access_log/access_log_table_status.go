@@ -0,0 +1,45 @@
+package access_log
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/dynamicrow"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// StatusAborted is the status Apache logs when a request is aborted before a response status is set
+// (e.g. the client disconnected while the request was being read)
+const StatusAborted = 0
+
+// statusTexts contains the reason phrases for status codes which Apache logs but are not defined by net/http
+var statusTexts = map[int]string{
+	StatusAborted: "Aborted",
+}
+
+// enrichStatus populates the columns derived from the response status
+func enrichStatus(row *types.DynamicRow) {
+	value, ok := dynamicrow.SourceValue(row, "status")
+	if !ok {
+		return
+	}
+	status, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+
+	// status_class is only set for valid HTTP status codes
+	if status >= 100 && status <= 599 {
+		row.OutputColumns["status_class"] = fmt.Sprintf("%dxx", status/100)
+	}
+	row.OutputColumns["is_client_error"] = status >= 400 && status <= 499
+	row.OutputColumns["is_server_error"] = status >= 500 && status <= 599
+	row.OutputColumns["is_error"] = status >= 400 && status <= 599
+
+	if text, ok := statusTexts[status]; ok {
+		row.OutputColumns["status_text"] = text
+	} else if text := http.StatusText(status); text != "" {
+		row.OutputColumns["status_text"] = text
+	}
+}
@@ -0,0 +1,161 @@
+package access_log
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// cidrTrie is a binary trie of IP prefixes, used to efficiently find all the threat intel lists containing an address
+type cidrTrie struct {
+	v4 *cidrTrieNode
+	v6 *cidrTrieNode
+}
+
+type cidrTrieNode struct {
+	children [2]*cidrTrieNode
+	// the names of the lists which contain a prefix ending at this node
+	lists []string
+}
+
+func newCidrTrie() *cidrTrie {
+	return &cidrTrie{
+		v4: &cidrTrieNode{},
+		v6: &cidrTrieNode{},
+	}
+}
+
+// insert adds a prefix belonging to the named list
+func (t *cidrTrie) insert(prefix netip.Prefix, list string) {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+	node := t.v6
+	if addr.Is4() {
+		node = t.v4
+	}
+
+	bytes := addr.AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := (bytes[i/8] >> (7 - i%8)) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &cidrTrieNode{}
+		}
+		node = node.children[bit]
+	}
+	if !slices.Contains(node.lists, list) {
+		node.lists = append(node.lists, list)
+	}
+}
+
+// lookup returns the names of all lists with a prefix containing the address
+func (t *cidrTrie) lookup(addr netip.Addr, res []string) []string {
+	addr = addr.Unmap()
+	node := t.v6
+	if addr.Is4() {
+		node = t.v4
+	}
+
+	bytes := addr.AsSlice()
+	for i := 0; node != nil; i++ {
+		for _, list := range node.lists {
+			if !slices.Contains(res, list) {
+				res = append(res, list)
+			}
+		}
+		if i == len(bytes)*8 {
+			break
+		}
+		node = node.children[(bytes[i/8]>>(7-i%8))&1]
+	}
+	return res
+}
+
+// loadThreatIntel builds a cidrTrie from the threat intel list files configured for the format
+// if no lists are configured, nil is returned
+func loadThreatIntel(format *AccessLogOptions) (*cidrTrie, error) {
+	if len(format.ThreatIntelLists) == 0 {
+		return nil, nil
+	}
+
+	trie := newCidrTrie()
+	// load in a consistent order so match results are deterministic
+	names := make([]string, 0, len(format.ThreatIntelLists))
+	for name := range format.ThreatIntelLists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := loadThreatIntelList(trie, name, format.ThreatIntelLists[name]); err != nil {
+			return nil, err
+		}
+	}
+	return trie, nil
+}
+
+// loadThreatIntelList adds the addresses in a list file to the trie
+// files may be plain text or CSV - for each line, comments (starting '#' or ';') are removed and the first
+// field which parses as an IP address or CIDR range is used, so formats such as the Spamhaus DROP list
+// ("1.10.16.0/20 ; SBL256894"), Tor exit node lists and simple CSV exports are all supported
+func loadThreatIntelList(trie *cidrTrie, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening threat intel list '%s': %w", name, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '"' }) {
+			prefix, ok, err := parseThreatIntelEntry(field)
+			if err != nil {
+				return fmt.Errorf("invalid entry in threat intel list '%s' at line %d: %w", name, lineNumber, err)
+			}
+			if ok {
+				trie.insert(prefix, name)
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading threat intel list '%s': %w", name, err)
+	}
+	return nil
+}
+
+// parseThreatIntelEntry parses an IP address or CIDR range into a prefix, returning false if the value is not an
+// address (e.g. another field of a CSV line), or an error if it is a range which cannot be matched
+func parseThreatIntelEntry(value string) (netip.Prefix, bool, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, false, nil
+		}
+		if prefix.Addr().Is4In6() {
+			// IPv4-mapped ranges are matched as IPv4 ranges, so must not include any addresses outside ::ffff:0:0/96
+			// (an invalid prefix would be inserted at the root of the trie, matching every address)
+			if prefix.Bits() < 96 {
+				return netip.Prefix{}, false, fmt.Errorf("IPv4-mapped range %s must have a prefix length of at least 96", value)
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		if !prefix.IsValid() {
+			return netip.Prefix{}, false, fmt.Errorf("invalid range %s", value)
+		}
+		return prefix, true, nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, false, nil
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true, nil
+}
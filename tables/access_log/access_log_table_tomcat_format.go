@@ -0,0 +1,185 @@
+package access_log
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const AccessLogTomcatFormatIdentifier = "apache_access_log_tomcat"
+
+// tomcatRegexMap is the Tomcat AccessLogValve pattern code table - this overlaps with the mod_log_config directive
+// table, but some codes have a different meaning (e.g. %S is the session ID rather than the bytes transferred)
+// %D is set by the duration unit of the format
+var tomcatRegexMap = map[string]string{
+	`%a`:                  `(?P<remote_addr>[^ ]*)`,              // remote IP address
+	`%A`:                  `(?P<local_addr>[^ ]*)`,               // local IP address
+	`%b`:                  `(?P<body_bytes_sent>[^ ]*)`,          // bytes sent, excluding HTTP headers (- if zero)
+	`%B`:                  `(?P<body_bytes_sent>[^ ]*)`,          // bytes sent, excluding HTTP headers
+	`%F`:                  `(?P<commit_time_ms>[^ ]*)`,           // time taken to commit the response in milliseconds
+	`%h`:                  `(?P<remote_addr>[^ ]*)`,              // remote host name (or IP address if enableLookups is false)
+	`%H`:                  `(?P<server_protocol>[^ ]*)`,          // request protocol
+	`%I`:                  `(?P<thread_name>[^ ]*)`,              // current request thread name
+	`%l`:                  `(?P<remote_logname>[^ ]*)`,           // remote logical username from identd (always -)
+	`%m`:                  `(?P<request_method>[^ ]*)`,           // request method
+	`%p`:                  `(?P<server_port>[^ ]*)`,              // local port on which the request was received
+	`%{local}p`:           `(?P<apache_port>[^ ]*)`,              // local port
+	`%{remote}p`:          `(?P<client_port>[^ ]*)`,              // remote port
+	`%q`:                  `(?P<query_string>[^ ]*)`,             // query string (prepended with a '?' if it exists)
+	`%r`:                  apacheRegexMap[`%r`],                  // first line of the request
+	`%s`:                  `(?P<status>[^ ]*)`,                   // HTTP status code of the response
+	`%S`:                  `(?P<servlet_session_id>[^ ]*)`,       // user session ID
+	`%T`:                  `(?P<request_time>[^ ]*)`,             // time taken to process the request in seconds
+	`%{s}T`:               `(?P<request_time>[^ ]*)`,             // time taken to process the request in seconds
+	`%{ms}T`:              `(?P<request_time_ms>[^ ]*)`,          // time taken to process the request in milliseconds
+	`%{us}T`:              `(?P<request_time_us>[^ ]*)`,          // time taken to process the request in microseconds
+	`%u`:                  `(?P<remote_user>[^ ]*)`,              // remote user that was authenticated
+	`%U`:                  `(?P<request_uri>[^ ?]*)`,             // requested URL path (which does not include the query string)
+	`%v`:                  `(?P<server_name>[^ ]*)`,              // local server name
+	`%X`:                  `(?P<connection_status>[^ ]*)`,        // connection status when the response is completed
+	`%{Referer}i`:         apacheRegexMap[`%{Referer}i`],         // Referer request header
+	`%{User-Agent}i`:      apacheRegexMap[`%{User-Agent}i`],      // User-Agent request header
+	`%{Host}i`:            apacheRegexMap[`%{Host}i`],            // Host request header
+	`%{X-Forwarded-For}i`: apacheRegexMap[`%{X-Forwarded-For}i`], // X-Forwarded-For request header
+}
+
+// tomcatNamedValueDirectives are the pattern codes which log a named value
+var tomcatNamedValueDirectives = map[string]namedValueDirective{
+	"i": namedValueDirectives["i"],
+	"o": namedValueDirectives["o"],
+	"c": namedValueDirectives["C"],
+}
+
+// tomcatPatternAliases are the pattern names Tomcat accepts in place of a pattern
+var tomcatPatternAliases = map[string]string{
+	"common":   `%h %l %u %t "%r" %s %b`,
+	"combined": `%h %l %u %t "%r" %s %b "%{Referer}i" "%{User-Agent}i"`,
+}
+
+// the units %D may be logged in
+const (
+	TomcatDurationUnitMilliseconds = "ms"
+	TomcatDurationUnitMicroseconds = "us"
+)
+
+// AccessLogTomcatFormat is the format of access logs written by the Tomcat AccessLogValve
+type AccessLogTomcatFormat struct {
+	AccessLogOptions
+	// required to allow partial decoding, as the options are decoded from the same body
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the AccessLogValve pattern (or common or combined)
+	Pattern string `hcl:"pattern"`
+	// the unit %D is logged in - ms (Tomcat 10.0 and earlier, the default) or us (Tomcat 10.1 and later)
+	DurationUnit *string `hcl:"duration_unit,optional"`
+}
+
+func NewAccessLogTomcatFormat() formats.Format {
+	return &AccessLogTomcatFormat{}
+}
+
+func (a *AccessLogTomcatFormat) Validate() error {
+	if err := a.validateOptions(a.Remain); err != nil {
+		return err
+	}
+	if a.DurationUnit != nil && *a.DurationUnit != TomcatDurationUnitMilliseconds && *a.DurationUnit != TomcatDurationUnitMicroseconds {
+		return fmt.Errorf("invalid duration_unit '%s': must be %s or %s", *a.DurationUnit, TomcatDurationUnitMilliseconds, TomcatDurationUnitMicroseconds)
+	}
+	_, err := a.parsePattern()
+	return err
+}
+
+// Identifier returns the format TYPE
+func (a *AccessLogTomcatFormat) Identifier() string {
+	return AccessLogTomcatFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *AccessLogTomcatFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *AccessLogTomcatFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *AccessLogTomcatFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *AccessLogTomcatFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	// convert the pattern to a regex
+	layout, err := a.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := newAccessLogMapper(layout.regex)
+	if err != nil {
+		return nil, err
+	}
+	mapper.namedValues = layout.namedValues
+	mapper.timestampFormats = layout.timestampFormats
+	mapper.includeRawLine = a.includesRawLine()
+	mapper.keepUnparsed = a.keepsUnparsedLines()
+	return mapper, nil
+}
+
+// GetRegex converts the pattern to a regex
+func (a *AccessLogTomcatFormat) GetRegex() (string, error) {
+	layout, err := a.parsePattern()
+	if err != nil {
+		return "", err
+	}
+	return layout.regex, nil
+}
+
+func (a *AccessLogTomcatFormat) GetProperties() map[string]string {
+	properties := map[string]string{
+		"pattern": a.Pattern,
+	}
+	if a.DurationUnit != nil {
+		properties["duration_unit"] = *a.DurationUnit
+	}
+	a.addProperties(properties)
+	return properties
+}
+
+// parsePattern converts the pattern to a regex
+//
+// Only the sec, msec and msec_frac formats of %{format}t are supported, as other formats use the Java
+// SimpleDateFormat syntax rather than strftime.
+func (a *AccessLogTomcatFormat) parsePattern() (*parsedLayout, error) {
+	pattern := a.Pattern
+	if alias, ok := tomcatPatternAliases[pattern]; ok {
+		pattern = alias
+	}
+
+	for _, match := range apacheDirectiveRegex.FindAllStringSubmatch(pattern, -1) {
+		if match[2] != "t" || match[1] == "" {
+			continue
+		}
+		format := strings.Trim(match[1], "{}")
+		format = strings.TrimPrefix(strings.TrimPrefix(format, "begin:"), "end:")
+		if !slices.Contains([]string{"sec", "msec", "msec_frac"}, format) {
+			return nil, fmt.Errorf("unsupported token in format: %s (only the sec, msec and msec_frac time formats are supported)", match[0])
+		}
+	}
+
+	regexMap := maps.Clone(tomcatRegexMap)
+	regexMap[`%D`] = `(?P<request_time_ms>[^ ]*)`
+	if a.DurationUnit != nil && *a.DurationUnit == TomcatDurationUnitMicroseconds {
+		regexMap[`%D`] = `(?P<request_time_us>[^ ]*)`
+	}
+	return parseLayout(pattern, layoutSyntax{regexMap: regexMap, namedValues: tomcatNamedValueDirectives})
+}
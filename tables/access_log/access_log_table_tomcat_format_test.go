@@ -0,0 +1,170 @@
+package access_log
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_AccessLogTomcatFormat_GetMapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  *AccessLogTomcatFormat
+		logLine string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "Common pattern alias",
+			format:  &AccessLogTomcatFormat{Pattern: "common"},
+			logLine: `127.0.0.1 - - [24/Feb/2025:12:34:56 +0000] "GET /manager/html HTTP/1.1" 401 2473`,
+			want: map[string]string{
+				"remote_addr":     "127.0.0.1",
+				"timestamp":       "24/Feb/2025:12:34:56 +0000",
+				"request_method":  "GET",
+				"request_uri":     "/manager/html",
+				"server_protocol": "HTTP/1.1",
+				"status":          "401",
+				"body_bytes_sent": "2473",
+			},
+		},
+		{
+			name:    "Tomcat specific pattern codes",
+			format:  &AccessLogTomcatFormat{Pattern: `%h %l %u %t "%r" %s %b %D %{X-Forwarded-For}i %S %I`},
+			logLine: `10.0.0.2 - - [24/Feb/2025:12:34:56 +0000] "GET /app/api/orders?id=7 HTTP/1.1" 200 1532 42 203.0.113.42 8F3C0E6E5B3A4C1D9E2F7A6B5C4D3E2F http-nio-8080-exec-7`,
+			want: map[string]string{
+				"remote_addr":          "10.0.0.2",
+				"status":               "200",
+				"body_bytes_sent":      "1532",
+				"request_time_ms":      "42",
+				"http_x_forwarded_for": "203.0.113.42",
+				"servlet_session_id":   "8F3C0E6E5B3A4C1D9E2F7A6B5C4D3E2F",
+				"thread_name":          "http-nio-8080-exec-7",
+			},
+		},
+		{
+			name:    "Microsecond duration, commit time, cookies and response headers",
+			format:  &AccessLogTomcatFormat{Pattern: `%a %{remote}p %t %m %U%q %s %D %F "%{JSESSIONID}c" "%{Content-Type}o"`, DurationUnit: &[]string{TomcatDurationUnitMicroseconds}[0]},
+			logLine: `192.168.1.1 51234 [24/Feb/2025:12:34:56 +0000] POST /login?next=%2F 302 20412 18 "8F3C0E6E5B3A4C1D" "text/html;charset=UTF-8"`,
+			want: map[string]string{
+				"client_port":      "51234",
+				"request_method":   "POST",
+				"request_uri":      "/login",
+				"query_string":     "?next=%2F",
+				"request_time_us":  "20412",
+				"commit_time_ms":   "18",
+				"cookies":          `{"JSESSIONID":"8F3C0E6E5B3A4C1D"}`,
+				"response_headers": `{"Content-Type":"text/html;charset=UTF-8"}`,
+			},
+		},
+		{
+			name:    "Epoch time",
+			format:  &AccessLogTomcatFormat{Pattern: `%{msec}t %a %s`},
+			logLine: `1740400496123 192.168.1.1 200`,
+			want: map[string]string{
+				"timestamp": "2025-02-24T12:34:56.123Z",
+			},
+		},
+		{
+			name:    "SimpleDateFormat time",
+			format:  &AccessLogTomcatFormat{Pattern: `%{yyyy-MM-dd HH:mm:ss}t %a %s`},
+			wantErr: true,
+		},
+		{
+			name:    "Session attribute",
+			format:  &AccessLogTomcatFormat{Pattern: `%a %{user}s %s`},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid duration unit",
+			format:  &AccessLogTomcatFormat{Pattern: "common", DurationUnit: &[]string{"ns"}[0]},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.format.Validate()
+			if err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("unexpected validation error: %v", err)
+			}
+			if tt.wantErr {
+				t.Fatalf("expected validation error")
+			}
+
+			mapper, err := tt.format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_AccessLogTomcatFormatPresets(t *testing.T) {
+	tests := []struct {
+		preset  string
+		logLine string
+		want    map[string]string
+	}{
+		{
+			preset:  "common",
+			logLine: `0:0:0:0:0:0:0:1 - admin [24/Feb/2025:12:34:56 +0100] "GET /manager/status HTTP/1.1" 200 7823`,
+			want: map[string]string{
+				"remote_addr":     "0:0:0:0:0:0:0:1",
+				"remote_user":     "admin",
+				"request_uri":     "/manager/status",
+				"status":          "200",
+				"body_bytes_sent": "7823",
+			},
+		},
+		{
+			preset:  "combined",
+			logLine: `203.0.113.42 - - [24/Feb/2025:12:34:56 +0000] "GET /petclinic/owners/find HTTP/1.1" 200 - "http://localhost:8080/petclinic/" "Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0"`,
+			want: map[string]string{
+				"request_uri":     "/petclinic/owners/find",
+				"body_bytes_sent": "-",
+				"http_referer":    "http://localhost:8080/petclinic/",
+				"http_user_agent": "Mozilla/5.0 (X11; Linux x86_64; rv:135.0) Gecko/20100101 Firefox/135.0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			var format *AccessLogTomcatFormat
+			for _, p := range AccessLogTomcatFormatPresets {
+				if f, ok := p.(*AccessLogTomcatFormat); ok && f.Name == tt.preset {
+					format = f
+				}
+			}
+			if format == nil {
+				t.Fatalf("preset %s not found", tt.preset)
+			}
+
+			mapper, err := format.GetMapper()
+			if err != nil {
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package config_directive
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/error_types"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/table"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ConfigDirectiveTableIdentifier = "apache_config_directive"
+
+// ConfigDirectiveTable - table for Apache httpd configuration files, with a row for each directive
+type ConfigDirectiveTable struct {
+	table.CustomTableImpl
+}
+
+func (c *ConfigDirectiveTable) Identifier() string {
+	return ConfigDirectiveTableIdentifier
+}
+
+func (c *ConfigDirectiveTable) GetDefaultFormat() formats.Format {
+	return DefaultConfigDirectiveFormat
+}
+
+func (c *ConfigDirectiveTable) GetTableDefinition() *schema.TableSchema {
+	return &schema.TableSchema{
+		Name: ConfigDirectiveTableIdentifier,
+		Columns: []*schema.ColumnSchema{
+			{
+				ColumnName:  "timestamp",
+				Description: "Modification time of the collected configuration file",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "config_file",
+				Description: "Path of the collected configuration file (e.g. /etc/apache2/apache2.conf)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "file",
+				Description: "Path of the file the directive was read from, which is an included file if it differs from config_file",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "line",
+				Description: "Line number of the directive within the file",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "sequence",
+				Description: "Position of the directive in the configuration, in the order Apache reads the directives of the configuration file and the files it includes - where a directive is set more than once, the last setting usually takes effect",
+				Type:        "integer",
+			},
+			// directive
+			{
+				ColumnName:  "directive",
+				Description: "Name of the directive, as written in the file - directive names are case-insensitive",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "arguments",
+				Description: "Arguments of the directive, as written in the file",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "argument_list",
+				Description: "Arguments of the directive, split into words with any quotes removed",
+				Type:        "varchar[]",
+			},
+			{
+				ColumnName:  "is_section",
+				Description: "True if the directive opens a section (e.g. <VirtualHost *:443>)",
+				Type:        "boolean",
+			},
+			// context
+			{
+				ColumnName:  "section",
+				Description: "Name of the innermost section enclosing the directive (e.g. VirtualHost, Directory), or null in the server config context",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "section_arguments",
+				Description: "Arguments of the innermost section enclosing the directive (e.g. *:443)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "context",
+				Description: "All the sections enclosing the directive, outermost first (e.g. <VirtualHost *:443><Directory /var/www/html>)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "depth",
+				Description: "Number of sections enclosing the directive",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "virtual_host",
+				Description: "Addresses of the virtual host enclosing the directive (e.g. *:443)",
+				Type:        "varchar",
+			},
+		},
+	}
+}
+
+func (c *ConfigDirectiveTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
+	// ask our CustomTableImpl for the mapper
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+	var includeRoot string
+	if format, ok := c.Format.(*ConfigDirectiveTableFormat); ok && format.IncludeRoot != nil {
+		includeRoot = *format.IncludeRoot
+	}
+
+	// which source do we support?
+	return []*table.SourceMetadata[*types.DynamicRow]{
+		{
+			// any artifact source
+			SourceName: constants.ArtifactSourceIdentifier,
+			Mapper:     mapper,
+			Options: []row_source.RowSourceOption{
+				// the config loader joins continuation lines, tracks sections and follows includes
+				artifact_source.WithArtifactLoader(newConfigDirectiveLoader(includeRoot)),
+			},
+		},
+	}, nil
+}
+
+func (c *ConfigDirectiveTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	ts, ok := row.GetSourceValue("timestamp")
+	if !ok {
+		return nil, error_types.NewRowErrorWithFields([]string{"timestamp"}, []string{})
+	}
+	t, err := helpers.ParseTime(ts)
+	if err != nil {
+		return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
+	}
+	row.OutputColumns[constants.TpTimestamp] = t
+
+	if isSection, ok := row.GetSourceValue("is_section"); ok {
+		row.OutputColumns["is_section"], _ = strconv.ParseBool(isSection)
+	}
+
+	arguments, _ := row.GetSourceValue("arguments")
+	args := splitConfigArguments(arguments)
+	if len(args) > 0 {
+		row.OutputColumns["argument_list"] = args
+	}
+
+	// tp_domains - the host names of virtual hosts
+	directive, _ := row.GetSourceValue("directive")
+	if strings.EqualFold(directive, "ServerName") || strings.EqualFold(directive, "ServerAlias") {
+		var domains []string
+		for _, arg := range args {
+			if host := serverNameHost(arg); host != "" && !slices.Contains(domains, host) {
+				domains = append(domains, host)
+			}
+		}
+		if len(domains) > 0 {
+			row.OutputColumns[constants.TpDomains] = domains
+		}
+	}
+
+	// now call the base class to do the rest of the enrichment
+	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+}
+
+// serverNameHost returns the host name of a ServerName or ServerAlias argument, which may include a scheme and
+// port - IP addresses and wildcard aliases are not host names, so return ""
+func serverNameHost(name string) string {
+	if _, rest, ok := strings.Cut(name, "://"); ok {
+		name = rest
+	}
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	if name == "" || strings.ContainsAny(name, "*?") {
+		return ""
+	}
+	if _, err := netip.ParseAddr(name); err == nil {
+		return ""
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,80 @@
+package config_directive
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ConfigDirectiveTableFormatIdentifier = "apache_config_directive"
+
+// ConfigDirectiveTableFormat is the format of the Apache httpd configuration files (httpd.conf, apache2.conf and
+// the files they include), with a directive on each line, optionally grouped in sections:
+//
+//	ServerTokens Prod
+//	<VirtualHost *:443>
+//	    ServerName www.example.com
+//	    SSLProtocol all -SSLv3 -TLSv1 -TLSv1.1
+//	</VirtualHost>
+//
+// The syntax of the configuration files cannot be configured, so the only argument of this format is the directory
+// the files matched by Include directives must be in.
+type ConfigDirectiveTableFormat struct {
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the directory included files must be in (e.g. /etc/httpd) - defaults to the directory of the collected
+	// configuration file
+	IncludeRoot *string `hcl:"include_root,optional"`
+}
+
+func NewConfigDirectiveTableFormat() formats.Format {
+	return &ConfigDirectiveTableFormat{}
+}
+
+func (a *ConfigDirectiveTableFormat) Validate() error {
+	if a.IncludeRoot != nil && !filepath.IsAbs(*a.IncludeRoot) {
+		return fmt.Errorf("invalid include_root '%s': must be an absolute path", *a.IncludeRoot)
+	}
+	return nil
+}
+
+// Identifier returns the format TYPE
+func (a *ConfigDirectiveTableFormat) Identifier() string {
+	return ConfigDirectiveTableFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *ConfigDirectiveTableFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *ConfigDirectiveTableFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *ConfigDirectiveTableFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *ConfigDirectiveTableFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &configDirectiveMapper{}, nil
+}
+
+// GetRegex returns N/A, as configuration files are not parsed with a regex
+func (a *ConfigDirectiveTableFormat) GetRegex() (string, error) {
+	return "N/A", nil
+}
+
+func (a *ConfigDirectiveTableFormat) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if a.IncludeRoot != nil {
+		properties["include_root"] = *a.IncludeRoot
+	}
+	return properties
+}
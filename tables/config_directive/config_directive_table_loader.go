@@ -0,0 +1,446 @@
+package config_directive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ConfigDirectiveLoaderIdentifier = "apache_config_directive_loader"
+
+// fileSourceIdentifier is the tp_source_type of artifacts collected by the file source - includes are only followed
+// for these artifacts, as they are read from the same machine as the files they include
+const fileSourceIdentifier = "file"
+
+// defineRegex matches the ${NAME} variables which may be used in the path of an Include directive
+var defineRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// configSection is a section (e.g. <VirtualHost *:443>) enclosing a directive
+type configSection struct {
+	name      string
+	arguments string
+}
+
+// configDirective is a directive read from a configuration file by the configDirectiveLoader
+type configDirective struct {
+	// the configuration file collected, and the file the directive was read from, which differ for included files
+	configFile string
+	file       string
+	// the 1-based line number of the first line of the directive
+	line int64
+	// the 1-based position of the directive in the configuration, in the order Apache reads the directives
+	sequence int64
+	// the modification time of the configuration file collected
+	time time.Time
+
+	directive string
+	arguments string
+	// true if the directive opens a section, e.g. <VirtualHost *:443>
+	isSection bool
+	// the sections enclosing the directive, outermost first
+	sections []configSection
+}
+
+// configDirectiveLoader is a Loader which reads the directives in an Apache configuration file, and in the files it
+// includes
+//
+// Continuation lines are joined, comments are skipped and the sections enclosing each directive are tracked.
+// The files matched by Include and IncludeOptional directives are read where the directive appears, so the
+// included directives are in the sections enclosing the Include directive - relative paths are resolved against
+// ServerRoot, or the directory of the configuration file if ServerRoot is not set. Includes are only followed for
+// artifacts collected by the file source, and only to files within the include root. Lines which are not
+// directives (e.g. a malformed section tag) are passed to the mapper as strings.
+type configDirectiveLoader struct {
+	// the directory included files must be in - if empty, the directory of the configuration file
+	includeRoot string
+}
+
+func newConfigDirectiveLoader(includeRoot string) *configDirectiveLoader {
+	return &configDirectiveLoader{includeRoot: includeRoot}
+}
+
+func (l *configDirectiveLoader) Identifier() string {
+	return ConfigDirectiveLoaderIdentifier
+}
+
+// Load implements Loader
+func (l *configDirectiveLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	slog.Debug("configDirectiveLoader Load", "path", info.LocalName)
+
+	stat, err := os.Stat(info.LocalName)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", info.LocalName, err)
+	}
+	f, err := os.Open(info.LocalName)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", info.LocalName, err)
+	}
+
+	configFile := info.Name
+	if configFile == "" {
+		configFile = info.LocalName
+	}
+	// artifacts from other sources are downloaded, so their includes do not refer to files on this machine
+	var includeRoot string
+	if info.SourceEnrichment != nil && info.SourceEnrichment.CommonFields.TpSourceType == fileSourceIdentifier {
+		includeRoot = l.includeRoot
+		if includeRoot == "" {
+			includeRoot = filepath.Dir(configFile)
+		}
+	}
+
+	go func() {
+		defer func() {
+			f.Close()
+			close(dataChan)
+		}()
+
+		r := newConfigReader(configFile, stat.ModTime(), includeRoot, func(data any) {
+			dataChan <- &types.RowData{Data: data}
+		})
+		if err := r.read(ctx, f, configFile); err != nil {
+			slog.Error("Error while reading artifact", "path", info.LocalName, "error", err)
+		}
+		slog.Debug("configDirectiveLoader Load complete", "path", info.LocalName)
+	}()
+	return nil
+}
+
+// configReader reads the directives of a configuration file and the files it includes
+type configReader struct {
+	configFile string
+	time       time.Time
+	onData     func(any)
+
+	// the directory included files must be in - if empty, includes are not followed
+	includeRoot string
+	// the directory relative Include paths are resolved against
+	serverRoot string
+	// the variables set with Define, which may be used in Include paths
+	defines map[string]string
+	// the sections enclosing the current line
+	sections []configSection
+	// the files currently being read, used to ignore recursive includes
+	files []string
+	// the number of directives read
+	count int64
+}
+
+func newConfigReader(configFile string, t time.Time, includeRoot string, onData func(any)) *configReader {
+	return &configReader{
+		configFile:  configFile,
+		time:        t,
+		onData:      onData,
+		includeRoot: includeRoot,
+		serverRoot:  filepath.Dir(configFile),
+		defines:     make(map[string]string),
+	}
+}
+
+// read reads the directives from r, which is the content of file
+func (c *configReader) read(ctx context.Context, r io.Reader, file string) error {
+	c.files = append(c.files, file)
+	// sections must be closed in the file which opens them
+	depth := len(c.sections)
+	defer func() {
+		c.files = c.files[:len(c.files)-1]
+		c.sections = c.sections[:depth]
+	}()
+
+	// lines longer than the maximum line length (including any continuation lines) are skipped, as a truncated
+	// directive would be recorded with the wrong arguments
+	var start int64
+	var pending strings.Builder
+	var skipping bool
+	err := artifact.ReadLines(ctx, r, func(l artifact.Line) {
+		if pending.Len() == 0 && !skipping {
+			start = l.Number
+		}
+		line := strings.TrimSpace(l.Text)
+		// a line ending with a backslash continues on the next line
+		continues := strings.HasSuffix(line, `\`) && !l.Truncated
+		line = strings.TrimSpace(strings.TrimSuffix(line, `\`))
+		if l.Truncated || pending.Len()+len(line) > artifact.MaxLineLength {
+			skipping = true
+			pending.Reset()
+		}
+		if !skipping {
+			pending.WriteString(line)
+		}
+		if continues {
+			if !skipping {
+				pending.WriteString(" ")
+			}
+			return
+		}
+
+		line = strings.TrimSpace(pending.String())
+		pending.Reset()
+		if skipping {
+			skipping = false
+			c.skipLongLine(file, start)
+			return
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			return
+		}
+		c.readLine(ctx, file, start, line)
+	})
+	if err != nil {
+		return err
+	}
+	// a continuation on the last line of the file
+	if skipping {
+		c.skipLongLine(file, start)
+	} else if line := strings.TrimSpace(pending.String()); line != "" && !strings.HasPrefix(line, "#") {
+		c.readLine(ctx, file, start, line)
+	}
+	return ctx.Err()
+}
+
+// skipLongLine logs a line which was skipped as it is longer than the maximum line length - the line number is
+// only logged for the configuration file collected, as the paths of included files are not logged
+func (c *configReader) skipLongLine(file string, number int64) {
+	if file == c.configFile {
+		slog.Warn("Skipping configuration line longer than the maximum line length", "file", file, "line", number, "max_length", artifact.MaxLineLength)
+		return
+	}
+	slog.Warn("Skipping included configuration line longer than the maximum line length", "file", c.configFile, "max_length", artifact.MaxLineLength)
+}
+
+// readLine handles a single directive or section tag
+func (c *configReader) readLine(ctx context.Context, file string, number int64, line string) {
+	if strings.HasPrefix(line, "</") {
+		name := strings.TrimSpace(strings.TrimSuffix(line[2:], ">"))
+		c.closeSection(name)
+		return
+	}
+
+	raw := line
+	directive := &configDirective{
+		configFile: c.configFile,
+		file:       file,
+		line:       number,
+		time:       c.time,
+		sections:   slices.Clone(c.sections),
+	}
+	if strings.HasPrefix(line, "<") {
+		if !strings.HasSuffix(line, ">") {
+			c.onData(raw)
+			return
+		}
+		directive.isSection = true
+		line = strings.TrimSpace(line[1 : len(line)-1])
+	}
+	name, arguments := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arguments = line[:i], line[i+1:]
+	}
+	if name == "" {
+		c.onData(raw)
+		return
+	}
+	c.count++
+	directive.sequence = c.count
+	directive.directive = name
+	directive.arguments = strings.TrimSpace(arguments)
+	c.onData(directive)
+
+	if directive.isSection {
+		c.sections = append(c.sections, configSection{name: name, arguments: directive.arguments})
+		return
+	}
+
+	switch strings.ToLower(name) {
+	case "serverroot":
+		if args := splitConfigArguments(directive.arguments); len(args) > 0 && len(c.sections) == 0 {
+			c.serverRoot = args[0]
+		}
+	case "define":
+		args := splitConfigArguments(directive.arguments)
+		switch len(args) {
+		case 1:
+			c.defines[args[0]] = ""
+		case 2:
+			c.defines[args[0]] = args[1]
+		}
+	case "include", "includeoptional":
+		args := splitConfigArguments(directive.arguments)
+		if len(args) == 0 {
+			return
+		}
+		c.include(ctx, directive, args[0])
+	}
+}
+
+// closeSection closes the innermost open section with the given name - closing tags which do not match an open
+// section are ignored
+func (c *configReader) closeSection(name string) {
+	for i := len(c.sections) - 1; i >= 0; i-- {
+		if strings.EqualFold(c.sections[i].name, name) {
+			c.sections = c.sections[:i]
+			return
+		}
+	}
+}
+
+// include reads the files matched by the path of an Include or IncludeOptional directive
+//
+// The path may be a file, a directory (all the files in which are included) or a wildcard pattern, and may use
+// variables set with Define - environment variables are not expanded. Files outside the include root and files
+// which cannot be read (including files which fail part way through) are skipped. The matched paths are not logged, as they may contain the values of variables.
+func (c *configReader) include(ctx context.Context, directive *configDirective, pattern string) {
+	if c.includeRoot == "" {
+		return
+	}
+	optional := strings.EqualFold(directive.directive, "includeoptional")
+	pattern = defineRegex.ReplaceAllStringFunc(pattern, func(v string) string {
+		if value, ok := c.defines[v[2:len(v)-1]]; ok {
+			return value
+		}
+		return v
+	})
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(c.serverRoot, pattern)
+	}
+
+	paths, unreadable, err := includePaths(pattern)
+	if unreadable > 0 {
+		slog.Warn("Ignoring unreadable files matched by Include directive", "file", directive.file, "line", directive.line, "count", unreadable)
+	}
+	if err != nil || len(paths) == 0 {
+		if !optional {
+			slog.Warn("Include directive matched no readable files", "file", directive.file, "line", directive.line)
+		}
+		return
+	}
+
+	for _, path := range paths {
+		if !c.inIncludeRoot(path) {
+			slog.Warn("Ignoring included file outside the include root", "file", directive.file, "line", directive.line)
+			continue
+		}
+		if slices.Contains(c.files, path) {
+			slog.Warn("Ignoring recursive Include directive", "file", directive.file, "line", directive.line)
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			slog.Warn("Error opening included file", "file", directive.file, "line", directive.line)
+			continue
+		}
+		err = c.read(ctx, f, path)
+		f.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		// the directives read before the error have already been sent
+		if err != nil {
+			slog.Warn("Error reading included file", "file", directive.file, "line", directive.line, "error", err)
+		}
+	}
+}
+
+// inIncludeRoot returns whether the file at path is within the include root, once any symbolic links are resolved
+func (c *configReader) inIncludeRoot(path string) bool {
+	root, err := filepath.EvalSymlinks(c.includeRoot)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// includePaths returns the files matched by the path of an Include directive, in the order Apache reads them,
+// and the number of matches which were skipped as they could not be read
+func includePaths(pattern string) ([]string, int, error) {
+	var matches []string
+	if strings.ContainsAny(pattern, "*?[") {
+		var err error
+		if matches, err = filepath.Glob(pattern); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		matches = []string{pattern}
+	}
+
+	var paths []string
+	var unreadable int
+	for _, match := range matches {
+		stat, err := os.Stat(match)
+		if err != nil {
+			unreadable++
+			continue
+		}
+		if !stat.IsDir() {
+			paths = append(paths, match)
+			continue
+		}
+		// a directory includes every file within it
+		_ = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				unreadable++
+			case !d.IsDir():
+				paths = append(paths, path)
+			}
+			return nil
+		})
+	}
+	return paths, unreadable, nil
+}
+
+// splitConfigArguments splits the arguments of a directive into words, as Apache does - arguments are separated
+// by whitespace, and may be enclosed in double or single quotes, within which the quote may be escaped with a
+// backslash
+func splitConfigArguments(arguments string) []string {
+	var words []string
+	var word strings.Builder
+	var quote byte
+	inWord := false
+	for i := 0; i < len(arguments); i++ {
+		ch := arguments[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && i+1 < len(arguments) && arguments[i+1] == quote {
+				word.WriteByte(quote)
+				i++
+			} else if ch == quote {
+				quote = 0
+			} else {
+				word.WriteByte(ch)
+			}
+		case ch == ' ' || ch == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case (ch == '"' || ch == '\'') && !inWord:
+			quote = ch
+			inWord = true
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
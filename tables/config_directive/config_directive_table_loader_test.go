@@ -0,0 +1,282 @@
+package config_directive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// readDirectives reads the config file at path, following includes within includeRoot, returning the directives
+// and invalid lines read
+func readDirectives(t *testing.T, path string, includeRoot string) []any {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []any
+	r := newConfigReader(path, time.Time{}, includeRoot, func(data any) {
+		got = append(got, data)
+	})
+	if err := r.read(context.Background(), f, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return got
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_configReader_Sections(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "httpd.conf")
+	writeFile(t, config, `# Security settings
+ServerTokens Prod
+TraceEnable off
+
+<VirtualHost *:443>
+	ServerName www.example.com
+	SSLProtocol all \
+	    -SSLv3 -TLSv1
+	<Directory "/var/www/html">
+		Require all granted
+	</Directory>
+	  # indented comment
+</virtualhost>
+<IfModule mod_status.c
+`)
+
+	vhost := configSection{name: "VirtualHost", arguments: "*:443"}
+	want := []any{
+		&configDirective{file: config, line: 2, sequence: 1, directive: "ServerTokens", arguments: "Prod"},
+		&configDirective{file: config, line: 3, sequence: 2, directive: "TraceEnable", arguments: "off"},
+		&configDirective{file: config, line: 5, sequence: 3, directive: "VirtualHost", arguments: "*:443", isSection: true},
+		&configDirective{file: config, line: 6, sequence: 4, directive: "ServerName", arguments: "www.example.com", sections: []configSection{vhost}},
+		&configDirective{file: config, line: 7, sequence: 5, directive: "SSLProtocol", arguments: "all -SSLv3 -TLSv1", sections: []configSection{vhost}},
+		&configDirective{file: config, line: 9, sequence: 6, directive: "Directory", arguments: `"/var/www/html"`, isSection: true, sections: []configSection{vhost}},
+		&configDirective{file: config, line: 10, sequence: 7, directive: "Require", arguments: "all granted", sections: []configSection{vhost, {name: "Directory", arguments: `"/var/www/html"`}}},
+		"<IfModule mod_status.c",
+	}
+
+	got := readDirectives(t, config, dir)
+	if len(got) != len(want) {
+		t.Fatalf("got %d items, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if d, ok := got[i].(*configDirective); ok {
+			d.configFile = ""
+			if len(d.sections) == 0 {
+				d.sections = nil
+			}
+		}
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("item %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func Test_configReader_Include(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "conf", "httpd.conf")
+	writeFile(t, config, `ServerRoot "`+dir+`"
+Define SITES sites-enabled
+Include conf.modules.d/*.conf
+<VirtualHost *:80>
+	IncludeOptional ${SITES}
+</VirtualHost>
+IncludeOptional missing/*.conf
+Include conf/httpd.conf
+Listen 80
+Header set X-Long \
+	`+strings.Repeat("a", artifact.MaxLineLength)+`
+ServerName www.example.com
+`)
+	writeFile(t, filepath.Join(dir, "conf.modules.d", "00-base.conf"), "LoadModule alias_module modules/mod_alias.so\n")
+	// a match which cannot be read does not prevent the other matches being read
+	if err := os.Symlink(filepath.Join(dir, "missing.conf"), filepath.Join(dir, "conf.modules.d", "05-broken.conf")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "conf.modules.d", "10-ssl.conf"), "LoadModule ssl_module modules/mod_ssl.so\n")
+	// lines longer than the maximum line length are skipped, in both included files and the file collected
+	writeFile(t, filepath.Join(dir, "conf.modules.d", "20-long.conf"), "Header set X-Long "+strings.Repeat("a", artifact.MaxLineLength)+"\nLoadModule headers_module modules/mod_headers.so\n")
+	writeFile(t, filepath.Join(dir, "conf.modules.d", "README"), "Not included\n")
+	// a section left open in an included file is closed at the end of that file
+	writeFile(t, filepath.Join(dir, "sites-enabled", "example.conf"), "ServerName www.example.com\n<Location /status>\n")
+
+	var got []string
+	for _, item := range readDirectives(t, config, dir) {
+		d := item.(*configDirective)
+		if d.configFile != config {
+			t.Errorf("config file: got %s, want %s", d.configFile, config)
+		}
+		got = append(got, directiveSummary(d))
+	}
+
+	want := []string{
+		"httpd.conf ServerRoot ",
+		"httpd.conf Define ",
+		"httpd.conf Include ",
+		"00-base.conf LoadModule ",
+		"10-ssl.conf LoadModule ",
+		"20-long.conf LoadModule ",
+		"httpd.conf VirtualHost ",
+		"httpd.conf IncludeOptional VirtualHost",
+		"example.conf ServerName VirtualHost",
+		"example.conf Location VirtualHost",
+		"httpd.conf IncludeOptional ",
+		// the recursive include is ignored
+		"httpd.conf Include ",
+		"httpd.conf Listen ",
+		"httpd.conf ServerName ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func Test_configReader_IncludeRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "apache2")
+	config := filepath.Join(root, "apache2.conf")
+	outside := filepath.Join(dir, "secrets", "outside.conf")
+	t.Setenv("APACHE_TEST_INCLUDE", filepath.Dir(outside))
+	writeFile(t, config, `Include `+outside+`
+Include ../secrets/*.conf
+Include ${APACHE_TEST_INCLUDE}/outside.conf
+Include linked.conf
+Include sites-enabled/*.conf
+`)
+	writeFile(t, outside, "SSLCertificateKeyFile /etc/ssl/private/key.pem\n")
+	writeFile(t, filepath.Join(root, "sites-enabled", "example.conf"), "ServerName www.example.com\n")
+	if err := os.Symlink(outside, filepath.Join(root, "linked.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		includeRoot string
+		want        []string
+	}{
+		{
+			name:        "files outside the include root are not read",
+			includeRoot: root,
+			want: []string{
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"example.conf ServerName ",
+			},
+		},
+		{
+			name: "includes are not followed without an include root",
+			want: []string{
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+				"apache2.conf Include ",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, item := range readDirectives(t, config, tt.includeRoot) {
+				got = append(got, directiveSummary(item.(*configDirective)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func Test_configDirectiveLoader_Load(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "apache2.conf")
+	writeFile(t, config, "Include ports.conf\n")
+	writeFile(t, filepath.Join(dir, "ports.conf"), "Listen 80\n")
+
+	tests := []struct {
+		sourceType string
+		want       []string
+	}{
+		{sourceType: fileSourceIdentifier, want: []string{"apache2.conf Include ", "ports.conf Listen "}},
+		// downloaded artifacts do not include files from the machine running the collection
+		{sourceType: "aws_s3_bucket", want: []string{"apache2.conf Include "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sourceType, func(t *testing.T) {
+			info := &types.DownloadedArtifactInfo{
+				ArtifactInfo: types.ArtifactInfo{
+					Name:             config,
+					SourceEnrichment: schema.NewSourceEnrichment(map[string]string{constants.TpSourceType: tt.sourceType}),
+				},
+				LocalName: config,
+			}
+			dataChan := make(chan *types.RowData)
+			if err := newConfigDirectiveLoader("").Load(context.Background(), info, dataChan); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for data := range dataChan {
+				got = append(got, directiveSummary(data.Data.(*configDirective)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// directiveSummary returns the file name, directive and enclosing sections of a directive
+func directiveSummary(d *configDirective) string {
+	var sections []string
+	for _, s := range d.sections {
+		sections = append(sections, s.name)
+	}
+	return strings.Join([]string{filepath.Base(d.file), d.directive, strings.Join(sections, ">")}, " ")
+}
+
+func Test_splitConfigArguments(t *testing.T) {
+	tests := []struct {
+		arguments string
+		want      []string
+	}{
+		{`all -SSLv3 -TLSv1`, []string{"all", "-SSLv3", "-TLSv1"}},
+		{`"/var/www/my site"  'single quoted'`, []string{"/var/www/my site", "single quoted"}},
+		{`combined "%h %l \"%r\"" env=!dontlog`, []string{"combined", `%h %l "%r"`, "env=!dontlog"}},
+		{`	`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arguments, func(t *testing.T) {
+			if got := splitConfigArguments(tt.arguments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config_directive
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// configDirectiveMapper maps a directive read by the configDirectiveLoader to a row
+type configDirectiveMapper struct{}
+
+func (m *configDirectiveMapper) Identifier() string {
+	return "apache_config_directive_mapper"
+}
+
+func (m *configDirectiveMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var d *configDirective
+	switch v := a.(type) {
+	case *configDirective:
+		d = v
+	case string:
+		return nil, fmt.Errorf("error parsing configuration line: not a directive: %s", v)
+	default:
+		return nil, fmt.Errorf("expected *configDirective or string, got %T", a)
+	}
+
+	fields := map[string]string{
+		"timestamp":   d.time.UTC().Format(time.RFC3339Nano),
+		"config_file": d.configFile,
+		"file":        d.file,
+		"line":        strconv.FormatInt(d.line, 10),
+		"sequence":    strconv.FormatInt(d.sequence, 10),
+		"directive":   d.directive,
+		"is_section":  strconv.FormatBool(d.isSection),
+		"depth":       strconv.Itoa(len(d.sections)),
+	}
+	if d.arguments != "" {
+		fields["arguments"] = d.arguments
+	}
+
+	if len(d.sections) > 0 {
+		innermost := d.sections[len(d.sections)-1]
+		fields["section"] = innermost.name
+		if innermost.arguments != "" {
+			fields["section_arguments"] = innermost.arguments
+		}
+
+		var sectionPath strings.Builder
+		for _, s := range d.sections {
+			sectionPath.WriteString("<" + s.name)
+			if s.arguments != "" {
+				sectionPath.WriteString(" " + s.arguments)
+			}
+			sectionPath.WriteString(">")
+
+			if strings.EqualFold(s.name, "VirtualHost") {
+				fields["virtual_host"] = s.arguments
+			}
+		}
+		fields["context"] = sectionPath.String()
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
@@ -0,0 +1,15 @@
+package config_directive
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+)
+
+// DefaultConfigDirectiveFormat is the Apache httpd configuration file syntax
+var DefaultConfigDirectiveFormat = &ConfigDirectiveTableFormat{
+	Name:        "default",
+	Description: "The Apache httpd configuration file syntax.",
+}
+
+var ConfigDirectiveTableFormatPresets = []formats.Format{
+	DefaultConfigDirectiveFormat,
+}
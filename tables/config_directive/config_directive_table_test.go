@@ -0,0 +1,113 @@
+package config_directive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/tabletest"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func Test_ConfigDirectiveTable_MapAndEnrichRow(t *testing.T) {
+	modTime := time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC)
+	vhost := configSection{name: "VirtualHost", arguments: "*:443"}
+
+	tests := []struct {
+		name    string
+		data    any
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "server config directive",
+			data: &configDirective{configFile: "/etc/apache2/apache2.conf", file: "/etc/apache2/conf-enabled/security.conf", line: 25, sequence: 180, time: modTime, directive: "ServerTokens", arguments: "Prod"},
+			want: map[string]any{
+				"timestamp":           modTime,
+				constants.TpTimestamp: modTime,
+				"config_file":         "/etc/apache2/apache2.conf",
+				"file":                "/etc/apache2/conf-enabled/security.conf",
+				"line":                "25",
+				"sequence":            "180",
+				"directive":           "ServerTokens",
+				"arguments":           "Prod",
+				"argument_list":       []string{"Prod"},
+				"is_section":          false,
+				"depth":               "0",
+				"section":             nil,
+				"context":             nil,
+				"virtual_host":        nil,
+			},
+		},
+		{
+			name: "directive in a directory of a virtual host",
+			data: &configDirective{configFile: "/etc/httpd/conf/httpd.conf", file: "/etc/httpd/conf/httpd.conf", line: 40, time: modTime, directive: "Options", arguments: "-Indexes +FollowSymLinks",
+				sections: []configSection{vhost, {name: "Directory", arguments: `"/var/www/html"`}}},
+			want: map[string]any{
+				"argument_list":     []string{"-Indexes", "+FollowSymLinks"},
+				"section":           "Directory",
+				"section_arguments": `"/var/www/html"`,
+				"context":           `<VirtualHost *:443><Directory "/var/www/html">`,
+				"depth":             "2",
+				"virtual_host":      "*:443",
+			},
+		},
+		{
+			name: "server aliases of a virtual host",
+			data: &configDirective{configFile: "/etc/httpd/conf/httpd.conf", file: "/etc/httpd/conf/httpd.conf", line: 41, time: modTime, directive: "serveralias", arguments: "Example.com *.example.com 10.0.0.5 https://shop.example.com:8443",
+				sections: []configSection{vhost}},
+			want: map[string]any{
+				constants.TpDomains: []string{"example.com", "shop.example.com"},
+			},
+		},
+		{
+			name: "section without arguments",
+			data: &configDirective{configFile: "/etc/httpd/conf/httpd.conf", file: "/etc/httpd/conf/httpd.conf", line: 50, time: modTime, directive: "Else", isSection: true},
+			want: map[string]any{
+				"is_section":    true,
+				"arguments":     nil,
+				"argument_list": nil,
+			},
+		},
+		{
+			name:    "invalid line",
+			data:    "<IfModule mod_status.c",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := tabletest.MapAndEnrich(t, &ConfigDirectiveTable{}, DefaultConfigDirectiveFormat, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tabletest.AssertColumns(t, row, tt.want)
+		})
+	}
+}
+
+// the mapper always sets the timestamp, so rows without a valid timestamp are enriched directly
+func Test_ConfigDirectiveTable_EnrichRow_InvalidTimestamp(t *testing.T) {
+	tests := map[string]map[string]string{
+		"missing timestamp":     {"directive": "ServerTokens", "arguments": "Prod"},
+		"unparseable timestamp": {"timestamp": "yesterday", "directive": "ServerTokens", "arguments": "Prod"},
+	}
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			row := &types.DynamicRow{}
+			if err := row.InitialiseFromMap(fields); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tabletest.Enrich(t, &ConfigDirectiveTable{}, DefaultConfigDirectiveFormat, row); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
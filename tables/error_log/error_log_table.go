@@ -0,0 +1,343 @@
+package error_log
+
+import (
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-apache/internal/dynamicrow"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/error_types"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/table"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ErrorLogTableIdentifier = "apache_error_log"
+const ErrorLogTableNilValue = "-"
+
+// errorCodeRegex matches the Apache message code (e.g. AH00128) at the start of a message
+var errorCodeRegex = regexp.MustCompile(`^(AH\d{5}): `)
+
+// ErrorLogTable - table for apache error logs
+type ErrorLogTable struct {
+	table.CustomTableImpl
+}
+
+func (c *ErrorLogTable) Identifier() string {
+	return ErrorLogTableIdentifier
+}
+
+func (c *ErrorLogTable) GetDefaultFormat() formats.Format {
+	return DefaultApacheErrorLogFormat
+}
+
+func (c *ErrorLogTable) GetTableDefinition() *schema.TableSchema {
+	return &schema.TableSchema{
+		Name: ErrorLogTableIdentifier,
+		Columns: []*schema.ColumnSchema{
+			// default format fields
+			{
+				ColumnName:  "timestamp",
+				Description: "Time the message was logged",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "module",
+				Description: "Name of the module logging the message (e.g. core, ssl, proxy)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "level",
+				Description: "Log level of the message (e.g. error, warn, notice, debug, trace1)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "pid",
+				Description: "Process ID of the process logging the message",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "thread_id",
+				Description: "Thread ID of the thread logging the message",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "source_file",
+				Description: "Source file name and line number of the log call (logged at debug level)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "os_error_code",
+				Description: "APR/OS error status code",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "os_error",
+				Description: "APR/OS error status string",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "client_addr",
+				Description: "IP address of the client associated with the message",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "client_port",
+				Description: "Port of the client associated with the message",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "error_code",
+				Description: "Apache message code (e.g. AH00128), used to look up the cause of the message",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "message",
+				Description: "The log message",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_referer",
+				Description: "Value of the 'Referer' request header",
+				Type:        "varchar",
+			},
+			// custom format fields
+			{
+				ColumnName:  "system_thread_id",
+				Description: "System unique thread ID of the thread logging the message",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "peer_addr",
+				Description: "IP address of the peer of the underlying connection",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "peer_port",
+				Description: "Port of the peer of the underlying connection",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "local_addr",
+				Description: "Local IP address of the connection",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "local_port",
+				Description: "Local port of the connection",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "server_name",
+				Description: "Name of the server (virtual host) handling the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "keepalive_requests",
+				Description: "Number of keep-alive requests handled on the connection",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "log_id",
+				Description: "Request log ID, as written to the access log (%L) for the same request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "connection_log_id",
+				Description: "Connection log ID, as written to the access log (%{c}L) for the same connection",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "unique_id",
+				Description: "Unique request identifier set by mod_unique_id",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_user_agent",
+				Description: "Value of the 'User-Agent' request header",
+				Type:        "varchar",
+			},
+			// mod_rewrite trace fields
+			{
+				ColumnName:  "rewrite_server_name",
+				Description: "Virtual host handling the request traced by mod_rewrite",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_server_id",
+				Description: "Internal ID of the virtual host handling the request traced by mod_rewrite (sid#)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_request_id",
+				Description: "Internal ID of the request traced by mod_rewrite (rid#), which is the same for every step of the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_request_type",
+				Description: "Type of the request traced by mod_rewrite (initial or subreq)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_redirect_count",
+				Description: "Number of internal redirects before the request traced by mod_rewrite",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "rewrite_perdir",
+				Description: "Directory of the per-directory (.htaccess or <Directory>) rules being applied",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_step",
+				Description: "mod_rewrite trace message, without the request prefix",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_action",
+				Description: "Rewrite step recorded by the trace message (e.g. apply_pattern, condition, rewrite, redirect, pass_through)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_pattern",
+				Description: "Pattern of the RewriteRule or RewriteCond being applied",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_input",
+				Description: "URI or test string the pattern is applied to, or the URI before the step",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_output",
+				Description: "URI after the step",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_flags",
+				Description: "Flags of the RewriteCond, or the result of the rule (e.g. REDIRECT/301, INTERNAL REDIRECT)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rewrite_result",
+				Description: "Result of the RewriteCond (matched or not-matched)",
+				Type:        "varchar",
+			},
+		},
+		NullIf: "-", // default null value
+	}
+}
+
+func (c *ErrorLogTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
+	// ask our CustomTableImpl for the mapper
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	// which source do we support?
+	return []*table.SourceMetadata[*types.DynamicRow]{
+		{
+			// any artifact source
+			SourceName: constants.ArtifactSourceIdentifier,
+			Mapper:     mapper,
+			Options: []row_source.RowSourceOption{
+				artifact_source.WithRowPerLine(),
+			},
+		},
+	}, nil
+}
+
+func (c *ErrorLogTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	if ts, ok := row.GetSourceValue("timestamp"); ok && ts != ErrorLogTableNilValue {
+		t, err := helpers.ParseTime(ts)
+		if err != nil {
+			return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
+		}
+		row.OutputColumns[constants.TpTimestamp] = t
+	}
+
+	// addresses are logged with their port (e.g. 192.168.1.1:51234) - split them into address and port columns
+	var ips []string
+	for _, prefix := range []string{"client", "peer", "local"} {
+		value, ok := dynamicrow.SourceValue(row, prefix+"_addr")
+		if !ok {
+			continue
+		}
+		addr, port := splitAddrPort(value)
+		row.OutputColumns[prefix+"_addr"] = addr
+		if port != "" {
+			row.OutputColumns[prefix+"_port"] = port
+		}
+		if !slices.Contains(ips, addr) {
+			ips = append(ips, addr)
+		}
+	}
+	if addr, ok := row.OutputColumns["client_addr"].(string); ok {
+		row.OutputColumns[constants.TpSourceIP] = addr
+	}
+	if len(ips) > 0 {
+		row.OutputColumns[constants.TpIps] = ips
+	}
+
+	// error_code
+	if message, ok := dynamicrow.SourceValue(row, "message"); ok {
+		if match := errorCodeRegex.FindStringSubmatch(message); match != nil {
+			row.OutputColumns["error_code"] = match[1]
+		}
+	}
+
+	// rewrite_* - the request and step traced by mod_rewrite
+	applyRewriteTrace(row)
+
+	// tp_akas - the ids which correlate the message with the access log
+	var akas []string
+	for _, field := range []string{"log_id", "connection_log_id", "unique_id"} {
+		if id, ok := dynamicrow.SourceValue(row, field); ok {
+			akas = append(akas, id)
+		}
+	}
+	if len(akas) > 0 {
+		row.OutputColumns[constants.TpAkas] = akas
+	}
+
+	// tp_domains
+	if serverName, ok := dynamicrow.SourceValue(row, "server_name"); ok {
+		row.OutputColumns[constants.TpDomains] = []string{strings.ToLower(serverName)}
+	}
+
+	// now call the base class to do the rest of the enrichment
+	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+}
+
+// splitAddrPort splits an address logged by Apache into the IP address and port
+// Apache does not bracket IPv6 addresses, so the port is only split from the address if the remainder is a valid IP
+// address (e.g. ::1:51234 is split into ::1 and 51234, but 2001:db8::1 is not split)
+func splitAddrPort(value string) (string, string) {
+	if ap, err := netip.ParseAddrPort(value); err == nil {
+		return ap.Addr().String(), strconv.Itoa(int(ap.Port()))
+	}
+	i := strings.LastIndex(value, ":")
+	if i == -1 {
+		return value, ""
+	}
+	if _, err := strconv.ParseUint(value[i+1:], 10, 16); err != nil {
+		return value, ""
+	}
+	// an IPv6 address without brackets - only split if the address without the port is valid,
+	// and the full value is not itself a valid address
+	if _, err := netip.ParseAddr(value); err == nil {
+		return value, ""
+	}
+	if addr, err := netip.ParseAddr(value[:i]); err == nil {
+		return addr.String(), value[i+1:]
+	}
+	return value, ""
+}
@@ -0,0 +1,158 @@
+package error_log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// errorLogRegexMap is the ErrorLogFormat directive table (without any modifiers)
+var errorLogRegexMap = map[string]string{
+	`%a`:             `(?P<client_addr>[^ \]]*)`,                                                         // client IP address and port
+	`%{c}a`:          `(?P<peer_addr>[^ \]]*)`,                                                           // IP address and port of the underlying connection peer
+	`%A`:             `(?P<local_addr>[^ \]]*)`,                                                          // local IP address and port
+	`%E`:             `\((?P<os_error_code>-?\d+)\)(?P<os_error>[^:\]]*)`,                                // APR/OS error status code and string
+	`%F`:             `(?P<source_file>[^ ]*)`,                                                           // source file name and line number of the log call
+	`%k`:             `(?P<keepalive_requests>[^ \]]*)`,                                                  // number of keep-alive requests on this connection
+	`%l`:             `(?P<level>[^ :\]]*)`,                                                              // loglevel of the message
+	`%L`:             `(?P<log_id>[^ \]]*)`,                                                              // log ID of the request
+	`%{c}L`:          `(?P<connection_log_id>[^ \]]*)`,                                                   // log ID of the connection
+	`%{C}L`:          `(?P<connection_log_id>[^ \]]*)`,                                                   // log ID of the connection, if in connection scope
+	`%m`:             `(?P<module>[^ :\]]*)`,                                                             // name of the module logging the message
+	`%M`:             `(?P<message>.*?)`,                                                                 // the actual log message
+	`%P`:             `(?P<pid>[^ :\]]*)`,                                                                // process ID of current process
+	`%T`:             `(?P<thread_id>[^ :\]]*)`,                                                          // thread ID of current thread
+	`%{g}T`:          `(?P<system_thread_id>[^ :\]]*)`,                                                   // system unique thread ID of current thread
+	`%t`:             `(?P<timestamp>[A-Za-z]{3} [A-Za-z]{3} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4})`, // the current time
+	`%{u}t`:          `(?P<timestamp>[A-Za-z]{3} [A-Za-z]{3} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4})`, // the current time including micro-seconds
+	`%{c}t`:          `(?P<timestamp>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?)`,                     // the current time in compact ISO 8601 format
+	`%{cu}t`:         `(?P<timestamp>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?)`,                     // the current time in compact ISO 8601 format, including micro-seconds
+	`%v`:             `(?P<server_name>[^ \]]*)`,                                                         // the canonical ServerName of the current server
+	`%V`:             `(?P<server_name>[^ \]]*)`,                                                         // the server name of the server serving the request (UseCanonicalName)
+	`%{Referer}i`:    `(?P<http_referer>\S*)`,                                                            // Referer request header
+	`%{User-Agent}i`: `(?P<http_user_agent>.*?)`,                                                         // User-Agent request header
+	`%{UNIQUE_ID}e`:  `(?P<unique_id>[^ \]]*)`,                                                           // mod_unique_id request identifier
+}
+
+// errorLogDirectiveRegex matches an ErrorLogFormat field separator ('\ ' or '% ') or directive - a '%' followed by
+// any modifiers ('-' to log '-' for an empty value, '+' to omit the line, or a minimum field width) and {argument},
+// then the directive letter
+var errorLogDirectiveRegex = regexp.MustCompile(`\\ |% |%([-+]?\d*)(?:\{([^}]*)\})?([a-zA-Z%])`)
+
+type ErrorLogTableFormat struct {
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the layout of the log line, using the ErrorLogFormat syntax
+	Layout string `hcl:"layout"`
+}
+
+func NewErrorLogTableFormat() formats.Format {
+	return &ErrorLogTableFormat{}
+}
+
+func (a *ErrorLogTableFormat) Validate() error {
+	return nil
+}
+
+// Identifier returns the format TYPE
+func (a *ErrorLogTableFormat) Identifier() string {
+	// format name is same as table name
+	return ErrorLogTableIdentifier
+}
+
+// GetName returns the format instance name
+func (a *ErrorLogTableFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *ErrorLogTableFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *ErrorLogTableFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *ErrorLogTableFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	// convert the layout to a regex
+	regex, err := a.GetRegex()
+	if err != nil {
+		return nil, err
+	}
+	return mappers.NewRegexMapper[*types.DynamicRow](regex)
+}
+
+// GetRegex converts the layout to a regex
+//
+// An ErrorLogFormat layout is divided into fields by the '\ ' (space) and '% ' (no output) field separators - when
+// a directive produces no output (e.g. there is no client for a message logged at startup) Apache omits the field
+// containing it, so every field after the first is optional.
+func (a *ErrorLogTableFormat) GetRegex() (string, error) {
+	layout := a.Layout
+
+	var fields []string
+	var field strings.Builder
+	last := 0
+	for _, loc := range errorLogDirectiveRegex.FindAllStringSubmatchIndex(layout, -1) {
+		// escape the literal text preceding the directive
+		field.WriteString(regexp.QuoteMeta(layout[last:loc[0]]))
+		last = loc[1]
+
+		token := layout[loc[0]:loc[1]]
+		switch token {
+		case `\ `, `% `:
+			// start a new field, which includes the space written by '\ '
+			fields = append(fields, field.String())
+			field.Reset()
+			if token == `\ ` {
+				field.WriteString(` `)
+			}
+			continue
+		case `%%`:
+			field.WriteString(`%`)
+			continue
+		}
+
+		modifiers := layout[loc[2]:loc[3]]
+		key := "%" + layout[loc[6]:loc[7]]
+		if loc[4] != -1 {
+			key = "%{" + layout[loc[4]:loc[5]] + "}" + layout[loc[6]:loc[7]]
+		}
+		pattern, ok := errorLogRegexMap[key]
+		if !ok {
+			return "", fmt.Errorf("unsupported token in format: %s", token)
+		}
+		// values shorter than a minimum field width are padded with spaces
+		if strings.TrimLeft(modifiers, "-+") != "" {
+			pattern = ` *` + pattern + ` *`
+		}
+		field.WriteString(pattern)
+	}
+	field.WriteString(regexp.QuoteMeta(layout[last:]))
+	fields = append(fields, field.String())
+
+	if layout == "" {
+		return "", nil
+	}
+
+	regex := "^" + fields[0]
+	for _, f := range fields[1:] {
+		if f != "" {
+			regex += "(?:" + f + ")?"
+		}
+	}
+	return regex + "$", nil
+}
+
+func (a *ErrorLogTableFormat) GetProperties() map[string]string {
+	return map[string]string{
+		"layout": a.Layout,
+	}
+}
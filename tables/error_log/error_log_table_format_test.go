@@ -0,0 +1,213 @@
+package error_log
+
+import (
+	"regexp"
+	"testing"
+)
+
+func Test_ErrorLogTableFormat_GetRegex(t *testing.T) {
+	type args struct {
+		layout  string
+		logLine string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Default format for threaded MPMs",
+			args: args{
+				layout:  `[%{u}t] [%-m:%l] [pid %P:tid %T] %7F: %E: [client\ %a] %M% ,\ referer\ %{Referer}i`,
+				logLine: `[Mon Feb 24 12:34:56.123456 2025] [proxy:error] [pid 35708:tid 4328636416]    proxy_util.c(2020): (111)Connection refused: [client 192.168.1.1:51234] AH00957: HTTP: attempt to connect to 127.0.0.1:8080 (localhost) failed, referer https://example.com/`,
+			},
+			want: map[string]string{
+				"timestamp":     "Mon Feb 24 12:34:56.123456 2025",
+				"module":        "proxy",
+				"level":         "error",
+				"pid":           "35708",
+				"thread_id":     "4328636416",
+				"source_file":   "proxy_util.c(2020)",
+				"os_error_code": "111",
+				"os_error":      "Connection refused",
+				"client_addr":   "192.168.1.1:51234",
+				"message":       "AH00957: HTTP: attempt to connect to 127.0.0.1:8080 (localhost) failed",
+				"http_referer":  "https://example.com/",
+			},
+		},
+		{
+			name: "Fields which produce no output are omitted",
+			args: args{
+				layout:  `[%{u}t] [%-m:%l] [pid %P]% [client\ %a]\ %M`,
+				logLine: `[Mon Feb 24 12:34:56.123456 2025] [mpm_event:notice] [pid 1] AH00489: Apache/2.4.62 (Unix) configured -- resuming normal operations`,
+			},
+			want: map[string]string{
+				"module":      "mpm_event",
+				"level":       "notice",
+				"pid":         "1",
+				"client_addr": "",
+				"message":     "AH00489: Apache/2.4.62 (Unix) configured -- resuming normal operations",
+			},
+		},
+		{
+			name: "Request and connection log IDs",
+			args: args{
+				layout:  `[%{cu}t] [%-m:%l] [R:%L] [C:%{C}L] [%{UNIQUE_ID}e] [client\ %a] %M`,
+				logLine: `[2025-02-24 12:34:56.123456] [core:error] [R:Z7xlYH8AAQEAAAKx0dAAAAAM] [C:-] [Z7xlYH8AAQEAAAKx0dAAAAAM] [client 192.168.1.1:51234] AH00128: File does not exist: /var/www/html/favicon.ico`,
+			},
+			want: map[string]string{
+				"timestamp":         "2025-02-24 12:34:56.123456",
+				"log_id":            "Z7xlYH8AAQEAAAKx0dAAAAAM",
+				"connection_log_id": "-",
+				"unique_id":         "Z7xlYH8AAQEAAAKx0dAAAAAM",
+				"message":           "AH00128: File does not exist: /var/www/html/favicon.ico",
+			},
+		},
+		{
+			name: "Connection format",
+			args: args{
+				layout:  `[%{c}t] [C:%{c}L] local\ %A remote\ %{c}a keepalive\ %k`,
+				logLine: `[2025-02-24 12:34:56] [C:AAAAAAAAAAA] local 10.0.0.5:443 remote 192.168.1.1:51234 keepalive 3`,
+			},
+			want: map[string]string{
+				"timestamp":          "2025-02-24 12:34:56",
+				"connection_log_id":  "AAAAAAAAAAA",
+				"local_addr":         "10.0.0.5:443",
+				"peer_addr":          "192.168.1.1:51234",
+				"keepalive_requests": "3",
+			},
+		},
+		{
+			name: "Unsupported token",
+			args: args{
+				layout:  `[%t] [%l] %{X-Request-Id}i %M`,
+				logLine: `[Mon Feb 24 12:34:56 2025] [error] abc message`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		format := &ErrorLogTableFormat{
+			Layout: tt.args.layout,
+			Name:   "test",
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format.GetRegex()
+			if err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				t.Fatalf("expected error, got regex %s", got)
+			}
+
+			assertRegexGroups(t, got, tt.args.logLine, tt.want)
+		})
+	}
+}
+
+func Test_DefaultApacheErrorLogFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		logLine string
+		want    map[string]string
+	}{
+		{
+			name:    "Apache 2.4 with client and referer",
+			logLine: `[Mon Feb 24 12:34:56.123456 2025] [core:error] [pid 35708:tid 4328636416] [client 192.168.1.1:51234] AH00128: File does not exist: /var/www/html/favicon.ico, referer: https://example.com/`,
+			want: map[string]string{
+				"timestamp":    "Mon Feb 24 12:34:56.123456 2025",
+				"module":       "core",
+				"level":        "error",
+				"pid":          "35708",
+				"thread_id":    "4328636416",
+				"client_addr":  "192.168.1.1:51234",
+				"message":      "AH00128: File does not exist: /var/www/html/favicon.ico",
+				"http_referer": "https://example.com/",
+			},
+		},
+		{
+			name:    "Apache 2.4 with source file and OS error",
+			logLine: `[Mon Feb 24 12:34:56.123456 2025] [proxy:error] [pid 35708:tid 4328636416] proxy_util.c(2020): (111)Connection refused: [client 192.168.1.1:51234] AH00957: HTTP: attempt to connect to 127.0.0.1:8080 (localhost) failed`,
+			want: map[string]string{
+				"source_file":   "proxy_util.c(2020)",
+				"os_error_code": "111",
+				"os_error":      "Connection refused",
+				"client_addr":   "192.168.1.1:51234",
+				"message":       "AH00957: HTTP: attempt to connect to 127.0.0.1:8080 (localhost) failed",
+			},
+		},
+		{
+			name:    "Apache 2.4 server message without a client",
+			logLine: `[Mon Feb 24 12:34:56.123456 2025] [mpm_prefork:notice] [pid 1] AH00163: Apache/2.4.62 (Debian) configured -- resuming normal operations`,
+			want: map[string]string{
+				"module":      "mpm_prefork",
+				"level":       "notice",
+				"pid":         "1",
+				"client_addr": "",
+				"message":     "AH00163: Apache/2.4.62 (Debian) configured -- resuming normal operations",
+			},
+		},
+		{
+			name:    "Apache 2.4 mod_rewrite trace",
+			logLine: `[Mon Feb 24 12:34:56.123456 2025] [rewrite:trace3] [pid 1234:tid 5678] mod_rewrite.c(483): [client 192.168.1.1:51234] 192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial] applying pattern '^/old/(.*)$' to uri '/old/page'`,
+			want: map[string]string{
+				"module":      "rewrite",
+				"level":       "trace3",
+				"source_file": "mod_rewrite.c(483)",
+				"client_addr": "192.168.1.1:51234",
+				"message":     "192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial] applying pattern '^/old/(.*)$' to uri '/old/page'",
+			},
+		},
+		{
+			name:    "Apache 2.2",
+			logLine: `[Mon Feb 24 12:34:56 2025] [error] [client 192.168.1.1] client denied by server configuration: /var/www/html/private`,
+			want: map[string]string{
+				"timestamp":   "Mon Feb 24 12:34:56 2025",
+				"module":      "",
+				"level":       "error",
+				"client_addr": "192.168.1.1",
+				"message":     "client denied by server configuration: /var/www/html/private",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertRegexGroups(t, DefaultApacheErrorLogFormat.Layout, tt.logLine, tt.want)
+		})
+	}
+}
+
+// assertRegexGroups checks the regex matches the log line, and the named groups have the wanted values
+func assertRegexGroups(t *testing.T, regex string, logLine string, want map[string]string) {
+	t.Helper()
+
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		t.Fatalf("error regex compile failed: %v", err)
+	}
+	matches := re.FindStringSubmatch(logLine)
+	if matches == nil {
+		t.Fatalf("error regex %s did not match log line: %v", re.String(), logLine)
+	}
+
+	groups := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if i != 0 && name != "" {
+			groups[name] = matches[i]
+		}
+	}
+	for k, v := range want {
+		if gotV, ok := groups[k]; !ok {
+			t.Errorf("key %s not found in matches", k)
+		} else if gotV != v {
+			t.Errorf("key %s: got %q, want %q", k, gotV, v)
+		}
+	}
+}
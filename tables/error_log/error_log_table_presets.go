@@ -0,0 +1,18 @@
+package error_log
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+)
+
+// DefaultApacheErrorLogFormat matches the lines written by Apache when no ErrorLogFormat is configured, for both
+// Apache 2.4 ([module:level] [pid N:tid N]) and Apache 2.2 ([level]) - the source file, OS error, client and
+// referer are only logged when they are known
+var DefaultApacheErrorLogFormat = &formats.Regex{
+	Name:        "apache_error_default",
+	Description: "A default regex format that covers the Apache 2.2 and 2.4 default error log formats.",
+	Layout:      `^\[(?P<timestamp>[^\]]+)\] \[(?:(?P<module>[^:\] ]*):)?(?P<level>[^\] ]+)\](?: \[pid (?P<pid>\d+)(?::tid (?P<thread_id>\d+))?\])?(?: (?P<source_file>[^ \[]+\(\d+\)):)?(?: \((?P<os_error_code>-?\d+)\)(?P<os_error>[^:\[]*):)?(?: \[(?:client|remote) (?P<client_addr>[^\]]+)\])? (?P<message>.*?)(?:, referer:? (?P<http_referer>\S*))?$`,
+}
+
+var ErrorLogTableFormatPresets = []formats.Format{
+	DefaultApacheErrorLogFormat,
+}
@@ -0,0 +1,84 @@
+package error_log
+
+import (
+	"regexp"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/dynamicrow"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// rewriteTraceRegex matches the prefix mod_rewrite adds to its trace messages (LogLevel rewrite:trace1 to trace8)
+// - the client, the virtual host and the request, with the per-directory context for rules in .htaccess files or
+// <Directory> sections:
+//
+//	192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial] [perdir /var/www/html/] rewrite 'old' -> 'new'
+var rewriteTraceRegex = regexp.MustCompile(`^\S+ \S+ \S+ \[(?P<server_name>[^\]]*)/sid#(?P<server_id>[0-9a-fA-Fx]+)\]\[rid#(?P<request_id>[0-9a-fA-Fx]+)/(?P<request_type>initial|subreq)(?:/redir#(?P<redirect_count>\d+))?\] (?:\[perdir (?P<perdir>[^\]]*)\] )?(?P<step>.*)$`)
+
+// rewriteStep is a mod_rewrite trace message, and the action it records
+// the regex captures the pattern, input, output, flags and result of the step
+type rewriteStep struct {
+	action string
+	re     *regexp.Regexp
+}
+
+// rewriteSteps are the mod_rewrite trace messages which are parsed into the rewrite columns
+// any other message is only included in the rewrite_step column
+var rewriteSteps = []rewriteStep{
+	{"apply_pattern", regexp.MustCompile(`^applying pattern '(?P<pattern>.*)' to uri '(?P<input>.*)'$`)},
+	{"condition", regexp.MustCompile(`^RewriteCond: input='(?P<input>.*)' pattern='(?P<pattern>.*)'(?: \[(?P<flags>[^\]]*)\])? => (?P<result>matched|not-matched)$`)},
+	{"rewrite", regexp.MustCompile(`^rewrite '(?P<input>.*)' -> '(?P<output>.*)'$`)},
+	{"strip_prefix", regexp.MustCompile(`^strip per-dir prefix: (?P<input>.*) -> (?P<output>.*)$`)},
+	{"add_prefix", regexp.MustCompile(`^add per-dir prefix: (?P<input>.*) -> (?P<output>.*)$`)},
+	{"add_path_info", regexp.MustCompile(`^add path info postfix: (?P<input>.*) -> (?P<output>.*)$`)},
+	{"split_uri", regexp.MustCompile(`^split uri=(?P<input>.*) -> uri=(?P<output>.*), args=.*$`)},
+	{"redirect", regexp.MustCompile(`^(?:explicitly forcing )?redirect(?: to)? (?P<output>\S+) \[(?P<flags>REDIRECT/\d+)\]$`)},
+	{"internal_redirect", regexp.MustCompile(`^internal redirect with (?P<output>\S+) \[(?P<flags>INTERNAL REDIRECT)\]$`)},
+	{"proxy", regexp.MustCompile(`^go-ahead with proxy request (?P<output>\S+) \[(?P<flags>OK)\]$`)},
+	{"pass_through", regexp.MustCompile(`^pass through (?P<output>.*)$`)},
+	{"ignore", regexp.MustCompile(`^initial URL equal rewritten URL: (?P<output>\S+) \[(?P<flags>IGNORING REWRITE)\]$`)},
+	{"force_response_code", regexp.MustCompile(`^forcing responsecode (?P<flags>\d+) for (?P<input>.*)$`)},
+	{"local_path", regexp.MustCompile(`^local path result: (?P<output>.*)$`)},
+	{"prefix_document_root", regexp.MustCompile(`^prefixed with document_root to (?P<output>.*)$`)},
+}
+
+// applyRewriteTrace parses a mod_rewrite trace message into the rewrite columns
+// only messages logged by mod_rewrite are parsed
+func applyRewriteTrace(row *types.DynamicRow) {
+	if module, ok := dynamicrow.SourceValue(row, "module"); !ok || module != "rewrite" {
+		return
+	}
+	message, ok := dynamicrow.SourceValue(row, "message")
+	if !ok {
+		return
+	}
+	match := rewriteTraceRegex.FindStringSubmatch(message)
+	if match == nil {
+		return
+	}
+
+	set := func(column, value string) {
+		if value != "" {
+			row.OutputColumns[column] = value
+		}
+	}
+	for i, name := range rewriteTraceRegex.SubexpNames() {
+		if name != "" {
+			set("rewrite_"+name, match[i])
+		}
+	}
+
+	step := match[rewriteTraceRegex.SubexpIndex("step")]
+	for _, s := range rewriteSteps {
+		stepMatch := s.re.FindStringSubmatch(step)
+		if stepMatch == nil {
+			continue
+		}
+		row.OutputColumns["rewrite_action"] = s.action
+		for i, name := range s.re.SubexpNames() {
+			if name != "" {
+				set("rewrite_"+name, stepMatch[i])
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,169 @@
+package error_log
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func Test_ErrorLogTable_EnrichRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		source map[string]string
+		want   map[string]any
+	}{
+		{
+			name: "client address, error code and log ids",
+			source: map[string]string{
+				"timestamp":   "Mon Feb 24 12:34:56.123456 2025",
+				"client_addr": "192.168.1.1:51234",
+				"local_addr":  "10.0.0.5:443",
+				"message":     "AH00128: File does not exist: /var/www/html/favicon.ico",
+				"log_id":      "Z7xlYH8AAQEAAAKx0dAAAAAM",
+				"unique_id":   "Z7xlYH8AAQEAAAKx0dAAAAAN",
+			},
+			want: map[string]any{
+				"client_addr":        "192.168.1.1",
+				"client_port":        "51234",
+				"local_addr":         "10.0.0.5",
+				"local_port":         "443",
+				"error_code":         "AH00128",
+				constants.TpSourceIP: "192.168.1.1",
+				constants.TpIps:      []string{"192.168.1.1", "10.0.0.5"},
+				constants.TpAkas:     []string{"Z7xlYH8AAQEAAAKx0dAAAAAM", "Z7xlYH8AAQEAAAKx0dAAAAAN"},
+			},
+		},
+		{
+			name: "ipv6 address and apache 2.2 client without port",
+			source: map[string]string{
+				"timestamp":   "Mon Feb 24 12:34:56 2025",
+				"client_addr": "2001:db8::1",
+				"peer_addr":   "[2001:db8::2]:51234",
+				"message":     "client denied by server configuration: /var/www/html/private",
+				"log_id":      "-",
+			},
+			want: map[string]any{
+				"client_addr":    "2001:db8::1",
+				"client_port":    nil,
+				"peer_addr":      "2001:db8::2",
+				"peer_port":      "51234",
+				"error_code":     nil,
+				constants.TpAkas: nil,
+			},
+		},
+		{
+			name: "mod_rewrite rule applied to the initial request",
+			source: map[string]string{
+				"timestamp":   "Mon Feb 24 12:34:56.123456 2025",
+				"module":      "rewrite",
+				"level":       "trace3",
+				"client_addr": "192.168.1.1:51234",
+				"message":     "192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial] applying pattern '^/old/(.*)$' to uri '/old/page'",
+			},
+			want: map[string]any{
+				"rewrite_server_name":    "www.example.com",
+				"rewrite_server_id":      "55d1c8a3b8f8",
+				"rewrite_request_id":     "7f3a2c00a0a0",
+				"rewrite_request_type":   "initial",
+				"rewrite_redirect_count": nil,
+				"rewrite_perdir":         nil,
+				"rewrite_step":           "applying pattern '^/old/(.*)$' to uri '/old/page'",
+				"rewrite_action":         "apply_pattern",
+				"rewrite_pattern":        "^/old/(.*)$",
+				"rewrite_input":          "/old/page",
+			},
+		},
+		{
+			name: "mod_rewrite condition in a per-directory context after a redirect",
+			source: map[string]string{
+				"timestamp": "Mon Feb 24 12:34:56.123456 2025",
+				"module":    "rewrite",
+				"level":     "trace4",
+				"message":   "192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial/redir#1] [perdir /var/www/html/] RewriteCond: input='/var/www/html/index.php' pattern='!-f' [NC] => not-matched",
+			},
+			want: map[string]any{
+				"rewrite_redirect_count": "1",
+				"rewrite_perdir":         "/var/www/html/",
+				"rewrite_action":         "condition",
+				"rewrite_input":          "/var/www/html/index.php",
+				"rewrite_pattern":        "!-f",
+				"rewrite_flags":          "NC",
+				"rewrite_result":         "not-matched",
+			},
+		},
+		{
+			name: "mod_rewrite redirect",
+			source: map[string]string{
+				"timestamp": "Mon Feb 24 12:34:56.123456 2025",
+				"module":    "rewrite",
+				"level":     "trace2",
+				"message":   "192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial] redirect to https://www.example.com/new/page [REDIRECT/301]",
+			},
+			want: map[string]any{
+				"rewrite_action": "redirect",
+				"rewrite_output": "https://www.example.com/new/page",
+				"rewrite_flags":  "REDIRECT/301",
+			},
+		},
+		{
+			name: "mod_rewrite step without rewrite columns",
+			source: map[string]string{
+				"timestamp": "Mon Feb 24 12:34:56.123456 2025",
+				"module":    "rewrite",
+				"level":     "trace2",
+				"message":   "192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/subreq] explicitly forcing redirect with https://www.example.com/new/page",
+			},
+			want: map[string]any{
+				"rewrite_request_type": "subreq",
+				"rewrite_step":         "explicitly forcing redirect with https://www.example.com/new/page",
+				"rewrite_action":       nil,
+			},
+		},
+		{
+			name: "trace message from another module",
+			source: map[string]string{
+				"timestamp": "Mon Feb 24 12:34:56.123456 2025",
+				"module":    "core",
+				"level":     "trace3",
+				"message":   "192.168.1.1 - - [www.example.com/sid#55d1c8a3b8f8][rid#7f3a2c00a0a0/initial] applying pattern '^/old/(.*)$' to uri '/old/page'",
+			},
+			want: map[string]any{
+				"rewrite_request_id": nil,
+				"rewrite_action":     nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &ErrorLogTable{}
+			if err := table.Initialize(DefaultApacheErrorLogFormat, table.GetTableDefinition()); err != nil {
+				t.Fatalf("failed to initialise table: %v", err)
+			}
+			row := &types.DynamicRow{}
+			if err := row.InitialiseFromMap(tt.source); err != nil {
+				t.Fatalf("failed to initialise row: %v", err)
+			}
+			row, err := table.EnrichRow(row, schema.SourceEnrichment{})
+			if err != nil {
+				t.Fatalf("unexpected enrichment error: %v", err)
+			}
+
+			for k, want := range tt.want {
+				got := row.OutputColumns[k]
+				if want == nil {
+					if got != nil {
+						t.Errorf("%s: got %v, want nil", k, got)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %v, want %v", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package forensic_log
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/error_types"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/table"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ForensicLogTableIdentifier = "apache_forensic_log"
+
+// ForensicLogTable - table for mod_log_forensic logs, with a row for each request
+type ForensicLogTable struct {
+	table.CustomTableImpl
+}
+
+func (c *ForensicLogTable) Identifier() string {
+	return ForensicLogTableIdentifier
+}
+
+func (c *ForensicLogTable) GetDefaultFormat() formats.Format {
+	return DefaultForensicLogFormat
+}
+
+func (c *ForensicLogTable) GetTableDefinition() *schema.TableSchema {
+	return &schema.TableSchema{
+		Name: ForensicLogTableIdentifier,
+		Columns: []*schema.ColumnSchema{
+			{
+				ColumnName:  "forensic_id",
+				Description: "Forensic id of the request - the mod_unique_id identifier if that module is loaded, and logged in the access log with %{forensic-id}n",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "timestamp",
+				Description: "Time the request was received, to the second, decoded from the forensic id",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "pid",
+				Description: "Process ID of the child process which handled the request, if mod_unique_id is not loaded",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "is_completed",
+				Description: "True if the request completed - false if it was still being handled when the log file ended, for example if the child process handling it crashed",
+				Type:        "boolean",
+			},
+			// request
+			{
+				ColumnName:  "request_line",
+				Description: "First line of the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_method",
+				Description: "HTTP request method (e.g. GET, POST)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_uri",
+				Description: "Request URI, including the query string",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "server_protocol",
+				Description: "Request protocol (e.g. HTTP/1.1)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_host",
+				Description: "Host header of the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_user_agent",
+				Description: "User-Agent header of the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_referer",
+				Description: "Referer header of the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_x_forwarded_for",
+				Description: "X-Forwarded-For header of the request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_headers",
+				Description: "All request headers, keyed by header name",
+				Type:        "json",
+			},
+			// provenance
+			{
+				ColumnName:  "log_line",
+				Description: "Line number of the '+' record logged when the request started",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "completion_log_line",
+				Description: "Line number of the '-' record logged when the request completed",
+				Type:        "bigint",
+			},
+		},
+	}
+}
+
+func (c *ForensicLogTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
+	// ask our CustomTableImpl for the mapper
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	// which source do we support?
+	return []*table.SourceMetadata[*types.DynamicRow]{
+		{
+			// any artifact source
+			SourceName: constants.ArtifactSourceIdentifier,
+			Mapper:     mapper,
+			Options: []row_source.RowSourceOption{
+				// the forensic loader pairs the start and completion records of each request
+				artifact_source.WithArtifactLoader(newForensicLogLoader()),
+			},
+		},
+	}, nil
+}
+
+func (c *ForensicLogTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	ts, ok := row.GetSourceValue("timestamp")
+	if !ok {
+		return nil, error_types.NewRowErrorWithFields([]string{"timestamp"}, []string{})
+	}
+	t, err := helpers.ParseTime(ts)
+	if err != nil {
+		return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
+	}
+	row.OutputColumns[constants.TpTimestamp] = t
+
+	if completed, ok := row.GetSourceValue("is_completed"); ok {
+		row.OutputColumns["is_completed"], _ = strconv.ParseBool(completed)
+	}
+
+	// tp_akas - the forensic id correlates the request with the access and error logs
+	if id, ok := row.GetSourceValue("forensic_id"); ok {
+		row.OutputColumns[constants.TpAkas] = []string{id}
+	}
+
+	// tp_ips - the forensic log does not include the client address, so only forwarded addresses are known
+	if forwardedFor, ok := row.GetSourceValue("http_x_forwarded_for"); ok {
+		var ips []string
+		for _, value := range strings.Split(forwardedFor, ",") {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(value)); err == nil && !slices.Contains(ips, addr.String()) {
+				ips = append(ips, addr.String())
+			}
+		}
+		if len(ips) > 0 {
+			row.OutputColumns[constants.TpIps] = ips
+		}
+	}
+
+	// tp_domains
+	if host, ok := row.GetSourceValue("http_host"); ok {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if _, err := netip.ParseAddr(host); err != nil && host != "" {
+			row.OutputColumns[constants.TpDomains] = []string{strings.ToLower(host)}
+		}
+	}
+
+	// now call the base class to do the rest of the enrichment
+	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+}
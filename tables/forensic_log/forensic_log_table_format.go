@@ -0,0 +1,63 @@
+package forensic_log
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ForensicLogTableFormatIdentifier = "apache_forensic_log"
+
+// ForensicLogTableFormat is the format of the log written by mod_log_forensic (ForensicLog), which has a
+// '+' record containing the request line and headers when a request starts and a '-' record when it completes:
+//
+//	+yQtJf8CoAB4AAFNXBIEAAAAA|GET /index.html HTTP/1.1|Host:www.example.com|User-Agent:Mozilla/5.0%20(X11)
+//	-yQtJf8CoAB4AAFNXBIEAAAAA
+//
+// The log format cannot be configured, so this format has no arguments.
+type ForensicLogTableFormat struct {
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+}
+
+func NewForensicLogTableFormat() formats.Format {
+	return &ForensicLogTableFormat{}
+}
+
+func (a *ForensicLogTableFormat) Validate() error {
+	return nil
+}
+
+// Identifier returns the format TYPE
+func (a *ForensicLogTableFormat) Identifier() string {
+	return ForensicLogTableFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *ForensicLogTableFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *ForensicLogTableFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *ForensicLogTableFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *ForensicLogTableFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &forensicLogMapper{}, nil
+}
+
+// GetRegex returns N/A, as forensic records are not parsed with a regex
+func (a *ForensicLogTableFormat) GetRegex() (string, error) {
+	return "N/A", nil
+}
+
+func (a *ForensicLogTableFormat) GetProperties() map[string]string {
+	return map[string]string{}
+}
@@ -0,0 +1,138 @@
+package forensic_log
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ForensicLogLoaderIdentifier = "apache_forensic_log_loader"
+
+// forensicRequest is a request read from an artifact by the forensicLogLoader, pairing the '+' record
+// logged when the request started with the '-' record logged when it completed
+type forensicRequest struct {
+	id string
+	// the request line and headers logged in the '+' record, without the leading "+<id>|"
+	request string
+	// false if the '-' record was read without a '+' record, e.g. as the log was rotated while the request
+	// was being handled
+	hasRequest bool
+	// true if the '-' record was read
+	completed bool
+	// the 1-based line numbers of the '+' and '-' records within the artifact
+	line           int64
+	completionLine int64
+}
+
+// forensicLogLoader is a Loader which pairs the '+' and '-' records of each request in an artifact
+//
+// A request is sent to the mapper when its '-' record is read, and any requests which have not completed when
+// the end of the artifact is reached are sent with completed set to false - these requests were still being
+// handled when the log was rotated, or the child process handling them crashed. Lines which are not forensic
+// records are passed to the mapper as strings. mod_log_forensic logs every request header, so records can be much
+// longer than the 64KiB read by the SDK row loaders - records longer than artifact.MaxLineLength are truncated
+// rather than stopping the artifact. gzip, zstd and zip compressed artifacts are decompressed based on the file
+// extension.
+type forensicLogLoader struct{}
+
+func newForensicLogLoader() *forensicLogLoader {
+	return &forensicLogLoader{}
+}
+
+func (l *forensicLogLoader) Identifier() string {
+	return ForensicLogLoaderIdentifier
+}
+
+// Load implements Loader
+func (l *forensicLogLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	slog.Debug("forensicLogLoader Load", "path", info.LocalName)
+
+	reader, err := artifact.Open(info.LocalName)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			reader.Close()
+			close(dataChan)
+		}()
+
+		if err := pairRecords(ctx, reader, func(data any) {
+			dataChan <- &types.RowData{Data: data}
+		}); err != nil {
+			slog.Error("Error while reading artifact", "path", info.LocalName, "error", err)
+		}
+		slog.Debug("forensicLogLoader Load complete", "path", info.LocalName)
+	}()
+	return nil
+}
+
+// pairRecords reads the forensic records from the reader, calling onData with a *forensicRequest for each request,
+// or with the line for any line which is not a forensic record
+func pairRecords(ctx context.Context, r io.Reader, onData func(any)) error {
+	// the requests which have started but not completed, keyed by id
+	pending := make(map[string]*forensicRequest)
+
+	err := artifact.ReadLines(ctx, r, func(l artifact.Line) {
+		line := l.Text
+		if line == "" {
+			return
+		}
+		if l.Truncated {
+			slog.Warn("Truncated forensic record longer than the maximum line length", "line", l.Number, "max_length", artifact.MaxLineLength)
+		}
+		switch line[0] {
+		case '+':
+			id, request, ok := strings.Cut(line[1:], "|")
+			if !ok || id == "" {
+				onData(line)
+				return
+			}
+			// an id is only reused if a request was never completed, so send the earlier request now
+			if previous, ok := pending[id]; ok {
+				delete(pending, id)
+				onData(previous)
+			}
+			req := &forensicRequest{id: id, request: request, hasRequest: true, line: l.Number}
+			pending[id] = req
+		case '-':
+			id := line[1:]
+			if id == "" || strings.Contains(id, "|") {
+				onData(line)
+				return
+			}
+			req, ok := pending[id]
+			if ok {
+				delete(pending, id)
+			} else {
+				req = &forensicRequest{id: id}
+			}
+			req.completed = true
+			req.completionLine = l.Number
+			onData(req)
+		default:
+			onData(line)
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// any requests still pending did not complete - send them in the order they started, even if the rest of the
+	// artifact could not be read, as they are the evidence of crashed child processes
+	incomplete := slices.SortedFunc(maps.Values(pending), func(a, b *forensicRequest) int {
+		return cmp.Compare(a.line, b.line)
+	})
+	for _, req := range incomplete {
+		onData(req)
+	}
+	return err
+}
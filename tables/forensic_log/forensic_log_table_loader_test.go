@@ -0,0 +1,113 @@
+package forensic_log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+)
+
+func Test_pairRecords(t *testing.T) {
+	// a request with headers longer than the maximum line length
+	longRecord := "+3512:67bc6770:0|GET / HTTP/1.1|Cookie:" + strings.Repeat("a", artifact.MaxLineLength)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []any
+	}{
+		{
+			name: "interleaved requests are paired",
+			input: "+ZmLqGX8AAQEAAB2KqX4AAAAB|GET / HTTP/1.1|Host:www.example.com\n" +
+				"+ZmLqGX8AAQEAAB2KqX4AAAAC|GET /favicon.ico HTTP/1.1|Host:www.example.com\n" +
+				"-ZmLqGX8AAQEAAB2KqX4AAAAC\n" +
+				"-ZmLqGX8AAQEAAB2KqX4AAAAB\n",
+			want: []any{
+				&forensicRequest{id: "ZmLqGX8AAQEAAB2KqX4AAAAC", request: "GET /favicon.ico HTTP/1.1|Host:www.example.com", hasRequest: true, completed: true, line: 2, completionLine: 3},
+				&forensicRequest{id: "ZmLqGX8AAQEAAB2KqX4AAAAB", request: "GET / HTTP/1.1|Host:www.example.com", hasRequest: true, completed: true, line: 1, completionLine: 4},
+			},
+		},
+		{
+			name: "requests which never complete are sent at the end of the artifact",
+			input: "+3512:67bc6770:0|POST /cgi-bin/upload HTTP/1.1|Host:www.example.com\r\n" +
+				"+3513:67bc6770:1|GET /index.html HTTP/1.1\r\n" +
+				"-3513:67bc6770:1\r\n" +
+				"+3514:67bc6771:2|GET /about HTTP/1.1\r\n",
+			want: []any{
+				&forensicRequest{id: "3513:67bc6770:1", request: "GET /index.html HTTP/1.1", hasRequest: true, completed: true, line: 2, completionLine: 3},
+				&forensicRequest{id: "3512:67bc6770:0", request: "POST /cgi-bin/upload HTTP/1.1|Host:www.example.com", hasRequest: true, line: 1},
+				&forensicRequest{id: "3514:67bc6771:2", request: "GET /about HTTP/1.1", hasRequest: true, line: 4},
+			},
+		},
+		{
+			name:  "completion without a start record and invalid lines",
+			input: "-3512:67bc6770:0\n\nnot a forensic record\n+|GET / HTTP/1.1\n",
+			want: []any{
+				&forensicRequest{id: "3512:67bc6770:0", completed: true, completionLine: 1},
+				"not a forensic record",
+				"+|GET / HTTP/1.1",
+			},
+		},
+		{
+			name:  "a reused id sends the earlier request as incomplete",
+			input: "+3512:67bc6770:0|GET /a HTTP/1.1\n+3512:67bc6770:0|GET /b HTTP/1.1\n-3512:67bc6770:0\n",
+			want: []any{
+				&forensicRequest{id: "3512:67bc6770:0", request: "GET /a HTTP/1.1", hasRequest: true, line: 1},
+				&forensicRequest{id: "3512:67bc6770:0", request: "GET /b HTTP/1.1", hasRequest: true, completed: true, line: 2, completionLine: 3},
+			},
+		},
+		{
+			name:  "an over-long record is truncated and later records are still read",
+			input: longRecord + "\n+3513:67bc6770:1|GET /a HTTP/1.1\n-3512:67bc6770:0\n",
+			want: []any{
+				&forensicRequest{id: "3512:67bc6770:0", request: longRecord[len("+3512:67bc6770:0|"):artifact.MaxLineLength], hasRequest: true, completed: true, line: 1, completionLine: 3},
+				&forensicRequest{id: "3513:67bc6770:1", request: "GET /a HTTP/1.1", hasRequest: true, line: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []any
+			err := pairRecords(context.Background(), strings.NewReader(tt.input), func(data any) {
+				got = append(got, data)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pairRecords() got %d records:", len(got))
+				for _, g := range got {
+					if req, ok := g.(*forensicRequest); ok {
+						t.Errorf("  id %s, request of %d bytes, hasRequest %v, completed %v, lines %d-%d", req.id, len(req.request), req.hasRequest, req.completed, req.line, req.completionLine)
+						continue
+					}
+					t.Errorf("  %#v", g)
+				}
+			}
+		})
+	}
+}
+
+func Test_pairRecords_ReadError(t *testing.T) {
+	readErr := errors.New("unexpected EOF")
+	r := io.MultiReader(strings.NewReader("+3512:67bc6770:0|GET / HTTP/1.1\n"), iotest.ErrReader(readErr))
+
+	var got []any
+	err := pairRecords(context.Background(), r, func(data any) {
+		got = append(got, data)
+	})
+	if !errors.Is(err, readErr) {
+		t.Errorf("got error %v, want %v", err, readErr)
+	}
+	// the requests read before the error are still sent, as incomplete requests
+	want := []any{&forensicRequest{id: "3512:67bc6770:0", request: "GET / HTTP/1.1", hasRequest: true, line: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pairRecords() = %#v, want %#v", got, want)
+	}
+}
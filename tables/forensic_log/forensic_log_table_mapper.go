@@ -0,0 +1,161 @@
+package forensic_log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// forensicIdRegex matches the id mod_log_forensic generates when mod_unique_id is not loaded
+// (<pid>:<hex time>:<hex counter>)
+var forensicIdRegex = regexp.MustCompile(`^(\d+):([0-9a-fA-F]+):[0-9a-fA-F]+$`)
+
+// uniqueIdAlphabet is the base64 alphabet used by mod_unique_id
+const uniqueIdAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789@-"
+
+// forensicLogMapper maps a request read by the forensicLogLoader to a row
+type forensicLogMapper struct{}
+
+func (m *forensicLogMapper) Identifier() string {
+	return "apache_forensic_log_mapper"
+}
+
+func (m *forensicLogMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var req *forensicRequest
+	switch v := a.(type) {
+	case *forensicRequest:
+		req = v
+	case string:
+		return nil, fmt.Errorf("error parsing log line: not a forensic log record: %s", v)
+	default:
+		return nil, fmt.Errorf("expected *forensicRequest or string, got %T", a)
+	}
+
+	fields := map[string]string{
+		"forensic_id":  req.id,
+		"is_completed": strconv.FormatBool(req.completed),
+	}
+	// the time the request was received is encoded in the id
+	if t, ok := forensicIdTime(req.id); ok {
+		fields["timestamp"] = t.Format(time.RFC3339)
+	}
+	if match := forensicIdRegex.FindStringSubmatch(req.id); match != nil {
+		fields["pid"] = match[1]
+	}
+	if req.line > 0 {
+		fields["log_line"] = strconv.FormatInt(req.line, 10)
+	}
+	if req.completionLine > 0 {
+		fields["completion_log_line"] = strconv.FormatInt(req.completionLine, 10)
+	}
+	if req.hasRequest {
+		applyRequest(fields, req.request)
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
+
+// applyRequest populates the request columns from the request line and headers logged in a '+' record
+//
+// The request line and headers are separated by '|', and each header is logged as <name>:<value> - any '|', ':'
+// or '%' characters, and any non-printable characters, in the request line, header names and values are
+// escaped as %hh.
+func applyRequest(fields map[string]string, request string) {
+	parts := strings.Split(request, "|")
+
+	line := unescapeForensicValue(parts[0])
+	fields["request_line"] = line
+	method, rest, _ := strings.Cut(line, " ")
+	uri, protocol, _ := strings.Cut(rest, " ")
+	fields["request_method"] = method
+	if uri != "" {
+		fields["request_uri"] = uri
+	}
+	if protocol != "" {
+		fields["server_protocol"] = protocol
+	}
+
+	headers := make(map[string]string)
+	for _, part := range parts[1:] {
+		name, value, ok := strings.Cut(part, ":")
+		if !ok || name == "" {
+			continue
+		}
+		name = unescapeForensicValue(name)
+		value = unescapeForensicValue(value)
+		// a header sent more than once is combined, as if it had been sent as a single header
+		if existing, ok := headers[name]; ok {
+			value = existing + ", " + value
+		}
+		headers[name] = value
+
+		switch strings.ToLower(name) {
+		case "host":
+			fields["http_host"] = value
+		case "user-agent":
+			fields["http_user_agent"] = value
+		case "referer":
+			fields["http_referer"] = value
+		case "x-forwarded-for":
+			fields["http_x_forwarded_for"] = value
+		}
+	}
+	if len(headers) > 0 {
+		// a map of strings cannot fail to marshal
+		b, _ := json.Marshal(headers)
+		fields["request_headers"] = string(b)
+	}
+}
+
+// unescapeForensicValue decodes the %hh escapes in a logged value - values with invalid escapes are returned
+// unchanged
+func unescapeForensicValue(value string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// forensicIdTime returns the time the request was received, which is encoded in the forensic id
+//
+// The id is the mod_unique_id identifier if that module is loaded, which starts with the time as a 32-bit
+// big-endian number of seconds, encoded in mod_unique_id's base64 alphabet, otherwise it is generated
+// by mod_log_forensic as <pid>:<hex time>:<hex counter>.
+func forensicIdTime(id string) (time.Time, bool) {
+	if match := forensicIdRegex.FindStringSubmatch(id); match != nil {
+		secs, err := strconv.ParseInt(match[2], 16, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(secs, 0).UTC(), true
+	}
+
+	// the first six characters encode 36 bits, the first 32 of which are the time
+	if len(id) < 6 {
+		return time.Time{}, false
+	}
+	var bits uint64
+	for _, c := range []byte(id[:6]) {
+		i := strings.IndexByte(uniqueIdAlphabet, c)
+		if i < 0 {
+			return time.Time{}, false
+		}
+		bits = bits<<6 | uint64(i)
+	}
+	return time.Unix(int64(bits>>4), 0).UTC(), true
+}
@@ -0,0 +1,15 @@
+package forensic_log
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+)
+
+// DefaultForensicLogFormat is the format written by mod_log_forensic
+var DefaultForensicLogFormat = &ForensicLogTableFormat{
+	Name:        "default",
+	Description: "The mod_log_forensic log format.",
+}
+
+var ForensicLogTableFormatPresets = []formats.Format{
+	DefaultForensicLogFormat,
+}
@@ -0,0 +1,100 @@
+package forensic_log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/tabletest"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+)
+
+func Test_ForensicLogTable_MapAndEnrichRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    any
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "completed request with a unique id",
+			data: &forensicRequest{
+				id:             "ZmLqGX8AAQEAAB2KqX4AAAAB",
+				request:        "GET /search?q=a%7cb HTTP/1.1|Host:www.Example.com%3a8080|User-Agent:Mozilla/5.0%20(X11;%20Linux%20x86_64)|Accept:*/*|Cookie:a=1|Cookie:b=2",
+				hasRequest:     true,
+				completed:      true,
+				line:           10,
+				completionLine: 12,
+			},
+			want: map[string]any{
+				"forensic_id":         "ZmLqGX8AAQEAAB2KqX4AAAAB",
+				"timestamp":           time.Date(2024, 6, 7, 11, 8, 9, 0, time.UTC),
+				"is_completed":        true,
+				"request_line":        "GET /search?q=a|b HTTP/1.1",
+				"request_method":      "GET",
+				"request_uri":         "/search?q=a|b",
+				"server_protocol":     "HTTP/1.1",
+				"http_host":           "www.Example.com:8080",
+				"http_user_agent":     "Mozilla/5.0 (X11; Linux x86_64)",
+				"request_headers":     `{"Accept":"*/*","Cookie":"a=1, b=2","Host":"www.Example.com:8080","User-Agent":"Mozilla/5.0 (X11; Linux x86_64)"}`,
+				"log_line":            "10",
+				"completion_log_line": "12",
+				constants.TpAkas:      []string{"ZmLqGX8AAQEAAB2KqX4AAAAB"},
+				constants.TpDomains:   []string{"www.example.com"},
+			},
+		},
+		{
+			name: "incomplete request with a mod_log_forensic id",
+			data: &forensicRequest{
+				id:         "3512:67bc6770:1f",
+				request:    "POST /cgi-bin/upload HTTP/1.1|Host:192.168.1.10|X-Forwarded-For:203.0.113.7,%2010.0.0.1",
+				hasRequest: true,
+				line:       3,
+			},
+			want: map[string]any{
+				"timestamp":           time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC),
+				"pid":                 "3512",
+				"is_completed":        false,
+				"request_method":      "POST",
+				constants.TpIps:       []string{"203.0.113.7", "10.0.0.1"},
+				constants.TpDomains:   nil,
+				"completion_log_line": nil,
+			},
+		},
+		{
+			name: "completion record without a start record",
+			data: &forensicRequest{id: "3512:67bc6770:20", completed: true, completionLine: 1},
+			want: map[string]any{
+				"is_completed":    true,
+				"request_line":    nil,
+				"request_headers": nil,
+				"log_line":        nil,
+			},
+		},
+		{
+			name:    "id which does not encode the time (missing timestamp)",
+			data:    &forensicRequest{id: "req#1", completed: true, completionLine: 1},
+			wantErr: true,
+		},
+		{
+			name:    "line which is not a forensic record",
+			data:    "not a forensic record",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := tabletest.MapAndEnrich(t, &ForensicLogTable{}, DefaultForensicLogFormat, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tabletest.AssertColumns(t, row, tt.want)
+		})
+	}
+}
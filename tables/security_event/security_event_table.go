@@ -0,0 +1,208 @@
+package security_event
+
+import (
+	"net/netip"
+	"slices"
+	"strconv"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/error_types"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/table"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const SecurityEventTableIdentifier = "apache_security_event"
+
+// SecurityEventTable - table for the events logged by the suexec, mod_evasive and mod_qos security modules
+type SecurityEventTable struct {
+	table.CustomTableImpl
+}
+
+func (c *SecurityEventTable) Identifier() string {
+	return SecurityEventTableIdentifier
+}
+
+func (c *SecurityEventTable) GetDefaultFormat() formats.Format {
+	return DefaultSecurityEventFormat
+}
+
+func (c *SecurityEventTable) GetTableDefinition() *schema.TableSchema {
+	return &schema.TableSchema{
+		Name: SecurityEventTableIdentifier,
+		Columns: []*schema.ColumnSchema{
+			{
+				ColumnName:  "timestamp",
+				Description: "Time the event was logged",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "log_type",
+				Description: "Module which logged the event (suexec, mod_evasive or mod_qos)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "host",
+				Description: "Host name logged by syslog",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "pid",
+				Description: "Process ID which logged the event",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "level",
+				Description: "Severity of the event (e.g. error, crit)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "event_code",
+				Description: "mod_qos event code (e.g. 031 for QS_SrvMaxConnPerIP)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "action",
+				Description: "Action recorded by the event - exec if suexec ran a command, deny if suexec or mod_qos refused a request, blacklist if mod_evasive blacklisted a client, or log_only if a mod_qos rule in log only mode matched",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "message",
+				Description: "Message logged by the module",
+				Type:        "varchar",
+			},
+			// client
+			{
+				ColumnName:  "client_ip",
+				Description: "IP address of the client the event relates to",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "client_port",
+				Description: "Port of the client the event relates to, if logged in the error log",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "unique_id",
+				Description: "mod_unique_id identifier of the request the event relates to, if logged by mod_qos",
+				Type:        "varchar",
+			},
+			// rule
+			{
+				ColumnName:  "rule",
+				Description: "mod_qos directive of the rule which matched (e.g. QS_SrvMaxConnPerIP, QS_LocRequestLimit)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "rule_details",
+				Description: "Limits and values of the rule which matched, as logged by mod_qos (e.g. max=10, concurrent connections=11)",
+				Type:        "varchar",
+			},
+			// suexec
+			{
+				ColumnName:  "user_name",
+				Description: "Name of the user suexec ran the command as",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "group_name",
+				Description: "Name of the group suexec ran the command as",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "target_user",
+				Description: "User requested by the server (SuexecUserGroup, or ~user for UserDir requests), as a name or ID",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "target_group",
+				Description: "Group requested by the server, as a name or ID",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "command",
+				Description: "Command (CGI program) suexec ran or refused to run",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "target",
+				Description: "File or directory which caused suexec to refuse to run a command (e.g. a file writable by others)",
+				Type:        "varchar",
+			},
+		},
+	}
+}
+
+func (c *SecurityEventTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
+	// ask our CustomTableImpl for the mapper
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	logType := DefaultSecurityEventFormat.LogType
+	if format, ok := c.Format.(*SecurityEventTableFormat); ok {
+		logType = format.LogType
+	}
+
+	// which source do we support?
+	return []*table.SourceMetadata[*types.DynamicRow]{
+		{
+			// any artifact source
+			SourceName: constants.ArtifactSourceIdentifier,
+			Mapper:     mapper,
+			Options: []row_source.RowSourceOption{
+				// the security event loader skips the lines of other programs and modules
+				artifact_source.WithArtifactLoader(newSecurityEventLoader(logType)),
+			},
+		},
+	}, nil
+}
+
+func (c *SecurityEventTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	ts, ok := row.GetSourceValue("timestamp")
+	if !ok {
+		return nil, error_types.NewRowErrorWithFields([]string{"timestamp"}, []string{})
+	}
+	t, err := helpers.ParseTime(ts)
+	if err != nil {
+		return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
+	}
+	row.OutputColumns[constants.TpTimestamp] = t
+
+	// tp_source_ip / tp_ips - the error log includes the client port
+	if client, ok := row.GetSourceValue("client_ip"); ok {
+		if addrPort, err := netip.ParseAddrPort(client); err == nil {
+			client = addrPort.Addr().String()
+			row.OutputColumns["client_ip"] = client
+			row.OutputColumns["client_port"] = strconv.Itoa(int(addrPort.Port()))
+		}
+		if addr, err := netip.ParseAddr(client); err == nil {
+			row.OutputColumns[constants.TpSourceIP] = addr.String()
+			row.OutputColumns[constants.TpIps] = []string{addr.String()}
+		}
+	}
+
+	// tp_usernames
+	var usernames []string
+	for _, field := range []string{"user_name", "target_user"} {
+		if value, ok := row.GetSourceValue(field); ok && !slices.Contains(usernames, value) {
+			usernames = append(usernames, value)
+		}
+	}
+	if len(usernames) > 0 {
+		row.OutputColumns[constants.TpUsernames] = usernames
+	}
+
+	// tp_akas
+	if id, ok := row.GetSourceValue("unique_id"); ok {
+		row.OutputColumns[constants.TpAkas] = []string{id}
+	}
+
+	// now call the base class to do the rest of the enrichment
+	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+}
@@ -0,0 +1,113 @@
+package security_event
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const SecurityEventTableFormatIdentifier = "apache_security_event"
+
+// the log types supported by the format
+const (
+	LogTypeSuexec  = "suexec"
+	LogTypeEvasive = "mod_evasive"
+	LogTypeQos     = "mod_qos"
+)
+
+var logTypes = []string{LogTypeSuexec, LogTypeEvasive, LogTypeQos}
+
+// SecurityEventTableFormat is the format of a log containing the events of one of the Apache security modules:
+//
+//   - suexec - the suexec_log written by suexec, or suexec messages in syslog if suexec logs to syslog
+//     [2025-02-24 12:34:56]: uid: (alice/alice) gid: (alice/alice) cmd: index.php
+//   - mod_evasive - the messages logged to syslog by mod_evasive when it blacklists a client
+//     Feb 24 12:34:56 web1 mod_evasive[1234]: Blacklisting address 192.168.1.1: possible DoS attack.
+//   - mod_qos - the messages logged to the error log by mod_qos
+//     [Mon Feb 24 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] [client 192.168.1.1:51234] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1
+//
+// The modules share syslog and the error log with other programs and modules, so lines which are not events of
+// the log type are skipped.
+type SecurityEventTableFormat struct {
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the type of log - suexec, mod_evasive or mod_qos
+	LogType string `hcl:"log_type"`
+	// the time zone of timestamps which do not include one (e.g. Europe/London) - the suexec_log, BSD syslog and
+	// error log timestamps are written in the local time of the server, defaults to UTC
+	TimeZone *string `hcl:"time_zone,optional"`
+}
+
+func NewSecurityEventTableFormat() formats.Format {
+	return &SecurityEventTableFormat{}
+}
+
+func (a *SecurityEventTableFormat) Validate() error {
+	if !slices.Contains(logTypes, a.LogType) {
+		return fmt.Errorf("invalid log_type '%s': must be one of %s", a.LogType, strings.Join(logTypes, ", "))
+	}
+	if a.TimeZone != nil {
+		if _, err := time.LoadLocation(*a.TimeZone); err != nil || *a.TimeZone == "" {
+			return fmt.Errorf("invalid time_zone '%s': must be an IANA time zone name (e.g. Europe/London)", *a.TimeZone)
+		}
+	}
+	return nil
+}
+
+// Identifier returns the format TYPE
+func (a *SecurityEventTableFormat) Identifier() string {
+	return SecurityEventTableFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *SecurityEventTableFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *SecurityEventTableFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *SecurityEventTableFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *SecurityEventTableFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return &securityEventMapper{logType: a.LogType, location: a.location()}, nil
+}
+
+// location returns the time zone of timestamps which do not include one
+func (a *SecurityEventTableFormat) location() *time.Location {
+	if a.TimeZone == nil {
+		return time.UTC
+	}
+	// the time zone has been validated
+	loc, _ := time.LoadLocation(*a.TimeZone)
+	return loc
+}
+
+// GetRegex returns N/A, as each log type is parsed by its own set of regexes
+func (a *SecurityEventTableFormat) GetRegex() (string, error) {
+	return "N/A", nil
+}
+
+func (a *SecurityEventTableFormat) GetProperties() map[string]string {
+	properties := map[string]string{
+		"log_type": a.LogType,
+	}
+	if a.TimeZone != nil {
+		properties["time_zone"] = *a.TimeZone
+	}
+	return properties
+}
@@ -0,0 +1,63 @@
+package security_event
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const SecurityEventLoaderIdentifier = "apache_security_event_loader"
+
+// securityEventLoader is a Loader which reads an artifact line by line, passing only the lines which may be
+// events of the log type to the mapper - syslog and the error log contain the messages of many other programs
+// and modules, which would otherwise fail to map. As these messages may be very long, lines longer than
+// artifact.MaxLineLength are truncated rather than stopping the artifact. gzip, zstd and zip compressed artifacts
+// are decompressed based on the file extension.
+type securityEventLoader struct {
+	logType string
+}
+
+func newSecurityEventLoader(logType string) *securityEventLoader {
+	return &securityEventLoader{logType: logType}
+}
+
+func (l *securityEventLoader) Identifier() string {
+	return SecurityEventLoaderIdentifier
+}
+
+// Load implements Loader
+func (l *securityEventLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	slog.Debug("securityEventLoader Load", "path", info.LocalName, "log_type", l.logType)
+
+	reader, err := artifact.Open(info.LocalName)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			reader.Close()
+			close(dataChan)
+		}()
+
+		if err := filterLines(ctx, reader, l.logType, func(line string) {
+			dataChan <- &types.RowData{Data: line}
+		}); err != nil {
+			slog.Error("Error while reading artifact", "path", info.LocalName, "error", err)
+		}
+		slog.Debug("securityEventLoader Load complete", "path", info.LocalName)
+	}()
+	return nil
+}
+
+// filterLines reads the lines from the reader, calling onLine with each line which may be an event of the log type
+func filterLines(ctx context.Context, r io.Reader, logType string, onLine func(string)) error {
+	return artifact.ReadLines(ctx, r, func(l artifact.Line) {
+		if l.Text != "" && isSecurityEvent(logType, l.Text) {
+			onLine(l.Text)
+		}
+	})
+}
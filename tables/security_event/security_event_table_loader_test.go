@@ -0,0 +1,66 @@
+package security_event
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/artifact"
+)
+
+func Test_filterLines(t *testing.T) {
+	syslog := "Feb 24 12:34:50 web1 sshd[880]: Accepted publickey for alice from 192.168.1.5 port 51000\n" +
+		"Feb 24 12:34:56 web1 mod_evasive[1234]: Blacklisting address 192.168.1.1: possible DoS attack.\r\n" +
+		"Feb 24 12:34:57 web1 suexec[4321]: uid: (bob/bob) gid: (bob/bob) cmd: php-cgi\n" +
+		"\n"
+	errorLog := "[Mon Feb 24 12:34:55.000001 2025] [core:error] [pid 1234:tid 5678] [client 192.168.1.1:51234] AH00128: File does not exist: /var/www/html/favicon.ico\n" +
+		"[Mon Feb 24 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1\n"
+
+	tests := []struct {
+		name    string
+		logType string
+		input   string
+		want    []string
+	}{
+		{
+			name:    "mod_evasive in syslog",
+			logType: LogTypeEvasive,
+			input:   syslog,
+			want:    []string{"Feb 24 12:34:56 web1 mod_evasive[1234]: Blacklisting address 192.168.1.1: possible DoS attack."},
+		},
+		{
+			name:    "suexec in syslog",
+			logType: LogTypeSuexec,
+			input:   syslog,
+			want:    []string{"Feb 24 12:34:57 web1 suexec[4321]: uid: (bob/bob) gid: (bob/bob) cmd: php-cgi"},
+		},
+		{
+			name:    "mod_qos in the error log",
+			logType: LogTypeQos,
+			input:   errorLog,
+			want:    []string{"[Mon Feb 24 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1"},
+		},
+		{
+			// a message from another module longer than the maximum line length does not stop the artifact
+			name:    "mod_qos after an over-long line",
+			logType: LogTypeQos,
+			input:   "[Mon Feb 24 12:34:54.000001 2025] [php:error] [pid 1234:tid 5678] " + strings.Repeat("a", artifact.MaxLineLength) + "\n" + errorLog,
+			want:    []string{"[Mon Feb 24 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if err := filterLines(context.Background(), strings.NewReader(tt.input), tt.logType, func(line string) {
+				got = append(got, line)
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package security_event
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// syslogRegex matches a syslog line, with either a BSD (RFC 3164) or ISO 8601 timestamp, as written by rsyslog
+// and syslog-ng
+var syslogRegex = regexp.MustCompile(`^(?:<\d+>\d* ?)?(?P<timestamp>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}|\d{4}-\d{2}-\d{2}T\S+) (?P<host>\S+) (?P<program>[^\s\[:]+)(?:\[(?P<pid>\d+)\])?: (?P<message>.*)$`)
+
+// suexecLogRegex matches a line of the suexec_log
+var suexecLogRegex = regexp.MustCompile(`^\[(?P<timestamp>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\]: (?P<message>.*)$`)
+
+// the fields of the error log prefix, which are matched individually so custom ErrorLogFormats are supported
+var (
+	errorLogTimestampRegex = regexp.MustCompile(`^\[([^\]]+)\]`)
+	errorLogLevelRegex     = regexp.MustCompile(`\[(?:[\w.]+:)?(emerg|alert|crit|error|warn|notice|info|debug|trace\d)\]`)
+	errorLogPidRegex       = regexp.MustCompile(`\[pid (\d+)`)
+	errorLogClientRegex    = regexp.MustCompile(`\[client ([^\]]+)\]`)
+)
+
+// suexecLevelRegex matches the level some suexec messages start with
+var suexecLevelRegex = regexp.MustCompile(`^(emerg|alert|crit|error|warn|notice|info|debug): `)
+
+// suexecExecRegex matches the message suexec logs when it runs a command
+var suexecExecRegex = regexp.MustCompile(`^uid: \((?P<target_user>[^/)]*)/(?P<user_name>[^)]*)\) gid: \((?P<target_group>[^/)]*)/(?P<group_name>[^)]*)\) cmd: (?P<command>.*)$`)
+
+// suexecErrorRegexes match the messages suexec logs when it refuses to run a command - the named groups are the
+// columns the values are captured in
+var suexecErrorRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`^invalid target user (?:name|id): \((?P<target_user>[^)]*)\)`),
+	regexp.MustCompile(`^invalid target group name: \((?P<target_group>[^)]*)\)`),
+	regexp.MustCompile(`^target uid/gid \((?P<target_user>\d+)/(?P<target_group>\d+)\) mismatch`),
+	regexp.MustCompile(`^cannot run as forbidden uid \((?P<target_user>\d+)/(?P<command>[^)]*)\)`),
+	regexp.MustCompile(`^cannot run as forbidden gid \((?P<target_group>\d+)/(?P<command>[^)]*)\)`),
+	regexp.MustCompile(`^command not in docroot \((?P<target>[^)]*)\)`),
+	regexp.MustCompile(`^cannot stat program: \((?P<target>[^)]*)\)`),
+	regexp.MustCompile(`^(?:file|directory) is writable by others: \((?P<target>[^)]*)\)`),
+	regexp.MustCompile(`^file has no execute permission: \((?P<target>[^)]*)\)`),
+	regexp.MustCompile(`^cannot get docroot information \((?P<target>[^)]*)\)`),
+	regexp.MustCompile(`^cannot change to directory \((?P<target>[^)]*)\)`),
+	regexp.MustCompile(`^invalid command \((?P<command>[^)]*)\)`),
+	regexp.MustCompile(`exec failed \((?P<command>[^)]*)\)$`),
+}
+
+// evasiveRegex matches the message mod_evasive logs when it blacklists a client
+var evasiveRegex = regexp.MustCompile(`Blacklisting address (?P<client_ip>[^\s:]+|[0-9a-fA-F:]+): possible DoS attack`)
+
+// the parts of a mod_qos message - mod_qos(<event code>): <message>, with the rule, the client address (c=) and
+// the mod_unique_id identifier (id=) of the request
+var (
+	qosRegex            = regexp.MustCompile(`mod_qos\((?P<event_code>\d+)\): (?P<message>.*)$`)
+	qosRuleRegex        = regexp.MustCompile(`\b(QS_[A-Za-z]+)\*?`)
+	qosRuleDetailsRegex = regexp.MustCompile(`\brule(?: id)?(?: \([^)]*\))?: (.*?)(?:, c=.*)?$`)
+	qosClientRegex      = regexp.MustCompile(`, c=([^, ]+)`)
+	qosIdRegex          = regexp.MustCompile(`, id=([^, ]+)`)
+)
+
+// securityEventMapper maps a log line of a security module to a row
+type securityEventMapper struct {
+	logType string
+	// the time zone of timestamps which do not include one
+	location *time.Location
+}
+
+func (m *securityEventMapper) Identifier() string {
+	return "apache_security_event_mapper"
+}
+
+func (m *securityEventMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var line string
+	switch v := a.(type) {
+	case string:
+		line = v
+	case []byte:
+		line = string(v)
+	default:
+		return nil, fmt.Errorf("expected string or []byte, got %T", a)
+	}
+
+	fields, ok := parseSecurityEvent(m.logType, line, time.Now(), m.location)
+	if !ok {
+		return nil, fmt.Errorf("error parsing log line: not a %s event: %s", m.logType, line)
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
+
+// isSecurityEvent returns true if the line may be an event of the log type, so lines of other programs and
+// modules can be skipped before they are parsed
+func isSecurityEvent(logType string, line string) bool {
+	switch logType {
+	case LogTypeSuexec:
+		return strings.HasPrefix(line, "[") || strings.Contains(line, " suexec[") || strings.Contains(line, " suexec:")
+	case LogTypeEvasive:
+		return strings.Contains(line, "Blacklisting address")
+	case LogTypeQos:
+		return strings.Contains(line, "mod_qos(")
+	}
+	return false
+}
+
+// parseSecurityEvent parses a log line of the log type into fields, returning false if it is not an event
+// the current time is used as the year of BSD syslog timestamps, which do not include the year, and timestamps
+// which do not include a time zone are in loc
+func parseSecurityEvent(logType string, line string, now time.Time, loc *time.Location) (map[string]string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	fields := map[string]string{"log_type": logType}
+
+	// the prefix of the line, which depends on where the module logs to
+	var message string
+	if match := syslogRegex.FindStringSubmatch(line); match != nil {
+		// suexec lines are identified by the program, as the messages do not include the module
+		if logType == LogTypeSuexec && match[syslogRegex.SubexpIndex("program")] != "suexec" {
+			return nil, false
+		}
+		fields["timestamp"] = syslogTime(match[syslogRegex.SubexpIndex("timestamp")], now, loc)
+		fields["host"] = match[syslogRegex.SubexpIndex("host")]
+		setIfPresent(fields, "pid", match[syslogRegex.SubexpIndex("pid")])
+		message = match[syslogRegex.SubexpIndex("message")]
+	} else if match := suexecLogRegex.FindStringSubmatch(line); match != nil && logType == LogTypeSuexec {
+		fields["timestamp"] = localTime(match[suexecLogRegex.SubexpIndex("timestamp")], loc)
+		message = match[suexecLogRegex.SubexpIndex("message")]
+	} else if match := errorLogTimestampRegex.FindStringSubmatch(line); match != nil && logType != LogTypeSuexec {
+		fields["timestamp"] = localTime(match[1], loc)
+		if level := errorLogLevelRegex.FindStringSubmatch(line); level != nil {
+			fields["level"] = level[1]
+		}
+		if pid := errorLogPidRegex.FindStringSubmatch(line); pid != nil {
+			fields["pid"] = pid[1]
+		}
+		if client := errorLogClientRegex.FindStringSubmatch(line); client != nil {
+			fields["client_ip"] = client[1]
+		}
+		message = line
+	} else {
+		return nil, false
+	}
+
+	switch logType {
+	case LogTypeSuexec:
+		return fields, parseSuexecMessage(fields, message)
+	case LogTypeEvasive:
+		return fields, parseEvasiveMessage(fields, message)
+	case LogTypeQos:
+		return fields, parseQosMessage(fields, message)
+	}
+	return nil, false
+}
+
+// parseSuexecMessage parses a message logged by suexec - either the command it ran, or the reason it refused to
+// run a command
+func parseSuexecMessage(fields map[string]string, message string) bool {
+	if message == "" {
+		return false
+	}
+	if match := suexecLevelRegex.FindStringSubmatch(message); match != nil {
+		fields["level"] = match[1]
+		message = message[len(match[0]):]
+	}
+	fields["message"] = message
+
+	if match := suexecExecRegex.FindStringSubmatch(message); match != nil {
+		fields["action"] = "exec"
+		setGroups(fields, suexecExecRegex, match)
+		return true
+	}
+
+	// any other message means suexec refused to run the command
+	fields["action"] = "deny"
+	for _, re := range suexecErrorRegexes {
+		if match := re.FindStringSubmatch(message); match != nil {
+			setGroups(fields, re, match)
+			break
+		}
+	}
+	return true
+}
+
+// parseEvasiveMessage parses the message logged by mod_evasive when it blacklists a client
+func parseEvasiveMessage(fields map[string]string, message string) bool {
+	match := evasiveRegex.FindStringSubmatch(message)
+	if match == nil {
+		return false
+	}
+	fields["message"] = message[strings.Index(message, match[0]):]
+	fields["action"] = "blacklist"
+	fields["client_ip"] = match[evasiveRegex.SubexpIndex("client_ip")]
+	return true
+}
+
+// parseQosMessage parses a message logged by mod_qos, with the rule which was applied and the client it was applied to
+func parseQosMessage(fields map[string]string, message string) bool {
+	match := qosRegex.FindStringSubmatch(message)
+	if match == nil {
+		return false
+	}
+	fields["event_code"] = match[qosRegex.SubexpIndex("event_code")]
+	message = match[qosRegex.SubexpIndex("message")]
+	fields["message"] = message
+
+	if rule := qosRuleRegex.FindStringSubmatch(message); rule != nil {
+		fields["rule"] = rule[1]
+	}
+	if details := qosRuleDetailsRegex.FindStringSubmatch(message); details != nil {
+		setIfPresent(fields, "rule_details", details[1])
+	}
+	// the client address logged by mod_qos is used in preference to the error log client, which may be a proxy
+	if client := qosClientRegex.FindStringSubmatch(message); client != nil && client[1] != "NULL" {
+		fields["client_ip"] = client[1]
+	}
+	if id := qosIdRegex.FindStringSubmatch(message); id != nil && id[1] != "-" {
+		fields["unique_id"] = id[1]
+	}
+
+	switch {
+	case strings.Contains(message, "(log only)"):
+		fields["action"] = "log_only"
+	case strings.Contains(message, "access denied"):
+		fields["action"] = "deny"
+	}
+	return true
+}
+
+// syslogTime returns a syslog timestamp in a format the table can parse - BSD timestamps do not include the year
+// or time zone, so the year is the one in which the timestamp was most recently reached, and the time is in loc
+func syslogTime(value string, now time.Time, loc *time.Location) string {
+	if _, err := helpers.ParseTime(value); err == nil {
+		return value
+	}
+	t, err := time.ParseInLocation(time.Stamp, value, loc)
+	if err != nil {
+		return value
+	}
+	t = t.AddDate(now.In(loc).Year(), 0, 0)
+	// allow a day of clock skew before assuming the event was last year
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// localTime returns a timestamp which does not include a time zone in a format the table can parse, with the
+// time in loc - values which cannot be parsed are returned unchanged, so the row fails enrichment
+func localTime(value string, loc *time.Location) string {
+	t, err := helpers.ParseTime(value)
+	if err != nil {
+		return value
+	}
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	return t.Format(time.RFC3339Nano)
+}
+
+// setGroups sets the fields captured by the named groups of a regex
+func setGroups(fields map[string]string, re *regexp.Regexp, match []string) {
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			setIfPresent(fields, name, match[i])
+		}
+	}
+}
+
+func setIfPresent(fields map[string]string, key string, value string) {
+	if value != "" {
+		fields[key] = value
+	}
+}
@@ -0,0 +1,26 @@
+package security_event
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+)
+
+// DefaultSecurityEventFormat is the suexec_log format
+var DefaultSecurityEventFormat = &SecurityEventTableFormat{
+	Name:        "suexec",
+	Description: "suexec log, written to suexec_log or syslog.",
+	LogType:     LogTypeSuexec,
+}
+
+var SecurityEventTableFormatPresets = []formats.Format{
+	DefaultSecurityEventFormat,
+	&SecurityEventTableFormat{
+		Name:        "mod_evasive",
+		Description: "mod_evasive blacklisting messages, written to syslog.",
+		LogType:     LogTypeEvasive,
+	},
+	&SecurityEventTableFormat{
+		Name:        "mod_qos",
+		Description: "mod_qos messages, written to the Apache error log.",
+		LogType:     LogTypeQos,
+	},
+}
@@ -0,0 +1,243 @@
+package security_event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/tabletest"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func Test_SecurityEventTable_MapAndEnrichRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		logType  string
+		timeZone string
+		line     string
+		want     map[string]any
+		wantErr  bool
+	}{
+		{
+			name:    "suexec command",
+			logType: LogTypeSuexec,
+			line:    "[2025-02-24 12:34:56]: uid: (~alice/alice) gid: (1001/alice) cmd: index.cgi",
+			want: map[string]any{
+				constants.TpTimestamp: time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC),
+				"log_type":            "suexec",
+				"action":              "exec",
+				"target_user":         "~alice",
+				"user_name":           "alice",
+				"target_group":        "1001",
+				"group_name":          "alice",
+				"command":             "index.cgi",
+				constants.TpUsernames: []string{"alice", "~alice"},
+				"target":              nil,
+			},
+		},
+		{
+			name:     "suexec command in a time zone",
+			logType:  LogTypeSuexec,
+			timeZone: "America/New_York",
+			line:     "[2025-02-24 12:34:56]: uid: (~alice/alice) gid: (1001/alice) cmd: index.cgi",
+			want: map[string]any{
+				constants.TpTimestamp: time.Date(2025, 2, 24, 17, 34, 56, 0, time.UTC),
+			},
+		},
+		{
+			name:    "suexec refused a file writable by others",
+			logType: LogTypeSuexec,
+			line:    "[2025-02-24 12:34:57]: file is writable by others: (/home/alice/public_html/cgi-bin/index.cgi)",
+			want: map[string]any{
+				"action":  "deny",
+				"message": "file is writable by others: (/home/alice/public_html/cgi-bin/index.cgi)",
+				"target":  "/home/alice/public_html/cgi-bin/index.cgi",
+				"command": nil,
+			},
+		},
+		{
+			name:    "suexec uid mismatch with a level",
+			logType: LogTypeSuexec,
+			line:    "[2025-02-24 12:34:58]: crit: target uid/gid (1001/1001) mismatch with directory (0/0) or program (1001/1001)",
+			want: map[string]any{
+				"level":        "crit",
+				"action":       "deny",
+				"target_user":  "1001",
+				"target_group": "1001",
+			},
+		},
+		{
+			name:    "suexec logging to syslog",
+			logType: LogTypeSuexec,
+			line:    "2025-02-24T12:34:56.123456+00:00 web1 suexec[4321]: uid: (bob/bob) gid: (bob/bob) cmd: php-cgi",
+			want: map[string]any{
+				"host":      "web1",
+				"pid":       "4321",
+				"user_name": "bob",
+				"command":   "php-cgi",
+			},
+		},
+		{
+			name:     "bsd syslog timestamp in a time zone",
+			logType:  LogTypeSuexec,
+			timeZone: "Europe/London",
+			line:     "Jul  1 12:34:56 web1 suexec[4321]: uid: (bob/bob) gid: (bob/bob) cmd: php-cgi",
+			want: map[string]any{
+				"host":    "web1",
+				"command": "php-cgi",
+			},
+		},
+		{
+			name:    "syslog message from another program",
+			logType: LogTypeSuexec,
+			line:    "2025-02-24T12:34:56+00:00 web1 cron[99]: uid: (bob/bob) gid: (bob/bob) cmd: backup",
+			wantErr: true,
+		},
+		{
+			name:    "mod_evasive blacklisting",
+			logType: LogTypeEvasive,
+			line:    "2025-02-24T12:34:56+00:00 web1 mod_evasive[1234]: Blacklisting address 2001:db8::1: possible DoS attack.",
+			want: map[string]any{
+				"log_type":           "mod_evasive",
+				"action":             "blacklist",
+				"client_ip":          "2001:db8::1",
+				"message":            "Blacklisting address 2001:db8::1: possible DoS attack.",
+				constants.TpSourceIP: "2001:db8::1",
+				constants.TpIps:      []string{"2001:db8::1"},
+			},
+		},
+		{
+			name:    "mod_qos connection limit",
+			logType: LogTypeQos,
+			line:    "[Mon Feb 24 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] [client 10.0.0.1:51234] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1, id=Z7xlYH8AAQEAAAKx0dAAAAAM",
+			want: map[string]any{
+				constants.TpTimestamp: time.Date(2025, 2, 24, 12, 34, 56, 123456000, time.UTC),
+				"log_type":            "mod_qos",
+				"level":               "error",
+				"pid":                 "1234",
+				"event_code":          "031",
+				"action":              "deny",
+				"rule":                "QS_SrvMaxConnPerIP",
+				"rule_details":        "max=10, concurrent connections=11",
+				"client_ip":           "192.168.1.1",
+				"client_port":         nil,
+				"unique_id":           "Z7xlYH8AAQEAAAKx0dAAAAAM",
+				constants.TpAkas:      []string{"Z7xlYH8AAQEAAAKx0dAAAAAM"},
+			},
+		},
+		{
+			name:     "mod_qos connection limit in a time zone",
+			logType:  LogTypeQos,
+			timeZone: "Europe/London",
+			line:     "[Tue Jul 01 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1",
+			want: map[string]any{
+				constants.TpTimestamp: time.Date(2025, 7, 1, 11, 34, 56, 123456000, time.UTC),
+			},
+		},
+		{
+			name:    "mod_qos line with an unparseable timestamp",
+			logType: LogTypeQos,
+			line:    "[not a time] [qos:error] [pid 1234:tid 5678] mod_qos(031): access denied, QS_SrvMaxConnPerIP rule: max=10, concurrent connections=11, c=192.168.1.1",
+			wantErr: true,
+		},
+		{
+			name:    "mod_evasive line without a timestamp",
+			logType: LogTypeEvasive,
+			line:    "Blacklisting address 192.168.1.1: possible DoS attack.",
+			wantErr: true,
+		},
+		{
+			name:    "mod_qos location limit in log only mode with the error log client",
+			logType: LogTypeQos,
+			line:    "[Mon Feb 24 12:34:56.123456 2025] [qos:error] [pid 1234:tid 5678] [client 192.168.1.1:51234] mod_qos(010): access denied (log only), QS_LocRequestLimit* rule: /app(50), concurrent requests=51, c=NULL",
+			want: map[string]any{
+				"event_code":   "010",
+				"action":       "log_only",
+				"rule":         "QS_LocRequestLimit",
+				"rule_details": "/app(50), concurrent requests=51",
+				"client_ip":    "192.168.1.1",
+				"client_port":  "51234",
+			},
+		},
+		{
+			name:    "error log message from another module",
+			logType: LogTypeQos,
+			line:    "[Mon Feb 24 12:34:56.123456 2025] [core:error] [pid 1234:tid 5678] [client 192.168.1.1:51234] AH00128: File does not exist: /var/www/html/mod_qos(1)",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := &SecurityEventTableFormat{Name: "test", LogType: tt.logType}
+			if tt.timeZone != "" {
+				format.TimeZone = &tt.timeZone
+			}
+			row, err := tabletest.MapAndEnrich(t, &SecurityEventTable{}, format, tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tabletest.AssertColumns(t, row, tt.want)
+		})
+	}
+}
+
+// the mapper always sets the timestamp, so a row without one is enriched directly
+func Test_SecurityEventTable_EnrichRow_MissingTimestamp(t *testing.T) {
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(map[string]string{"log_type": LogTypeEvasive, "action": "blacklist"}); err != nil {
+		t.Fatal(err)
+	}
+	format := &SecurityEventTableFormat{Name: "test", LogType: LogTypeEvasive}
+	if _, err := tabletest.Enrich(t, &SecurityEventTable{}, format, row); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func Test_syslogTime(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		loc   *time.Location
+		want  string
+	}{
+		{"Jan  2 09:59:59", time.UTC, "2025-01-02T09:59:59Z"},
+		{"Dec 31 23:00:00", time.UTC, "2024-12-31T23:00:00Z"},
+		{"2025-01-02T09:59:59.123456+01:00", time.UTC, "2025-01-02T09:59:59.123456+01:00"},
+		{"Jan  2 04:59:59", newYork, "2025-01-02T04:59:59-05:00"},
+		// it is still the previous year in New York
+		{"Jan  1 12:00:00", newYork, "2025-01-01T12:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		if got := syslogTime(tt.value, now, tt.loc); got != tt.want {
+			t.Errorf("%s (%s): got %s, want %s", tt.value, tt.loc, got, tt.want)
+		}
+	}
+}
+
+func Test_SecurityEventTableFormat_Validate(t *testing.T) {
+	for _, format := range SecurityEventTableFormatPresets {
+		if err := format.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", format.GetName(), err)
+		}
+	}
+	if err := (&SecurityEventTableFormat{Name: "invalid", LogType: "mod_security"}).Validate(); err == nil {
+		t.Errorf("expected error for an invalid log_type")
+	}
+	for _, timeZone := range []string{"", "Mars/Olympus_Mons"} {
+		format := &SecurityEventTableFormat{Name: "invalid", LogType: LogTypeSuexec, TimeZone: &timeZone}
+		if err := format.Validate(); err == nil {
+			t.Errorf("expected error for time_zone '%s'", timeZone)
+		}
+	}
+}
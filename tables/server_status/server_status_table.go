@@ -0,0 +1,329 @@
+package server_status
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/error_types"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/table"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ServerStatusTableIdentifier = "apache_server_status"
+
+// ServerStatusTable - table for mod_status snapshots, with a row for each snapshot of each server
+type ServerStatusTable struct {
+	table.CustomTableImpl
+}
+
+func (c *ServerStatusTable) Identifier() string {
+	return ServerStatusTableIdentifier
+}
+
+func (c *ServerStatusTable) GetDefaultFormat() formats.Format {
+	return DefaultServerStatusFormat
+}
+
+func (c *ServerStatusTable) GetTableDefinition() *schema.TableSchema {
+	return &schema.TableSchema{
+		Name: ServerStatusTableIdentifier,
+		Columns: []*schema.ColumnSchema{
+			{
+				ColumnName:  "server",
+				Description: "Name of the server - the ServerName of the server in Apache 2.4, otherwise the host of the polled URL",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "timestamp",
+				Description: "Time the snapshot was taken",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "status_url",
+				Description: "URL the snapshot was fetched from, if it was polled",
+				Type:        "varchar",
+			},
+			// server
+			{
+				ColumnName:  "server_version",
+				Description: "Server version (e.g. Apache/2.4.62 (Debian))",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "server_mpm",
+				Description: "Multi-processing module the server is running (e.g. event, worker, prefork)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "server_built",
+				Description: "Time the server was built",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "restart_time",
+				Description: "Time the server was last restarted",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "parent_server_config_generation",
+				Description: "Configuration generation of the parent process, incremented by each graceful restart",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "parent_server_mpm_generation",
+				Description: "MPM generation of the parent process",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "uptime_seconds",
+				Description: "Number of seconds since the server was restarted",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "load_1",
+				Description: "System load average over the last minute",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "load_5",
+				Description: "System load average over the last 5 minutes",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "load_15",
+				Description: "System load average over the last 15 minutes",
+				Type:        "float",
+			},
+			// traffic
+			{
+				ColumnName:  "total_accesses",
+				Description: "Number of requests handled since the server was restarted",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "total_kbytes",
+				Description: "Number of kilobytes sent since the server was restarted",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "total_duration_ms",
+				Description: "Total time spent handling requests since the server was restarted, in milliseconds",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "requests_per_sec",
+				Description: "Average number of requests per second since the server was restarted",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "bytes_per_sec",
+				Description: "Average number of bytes sent per second since the server was restarted",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "bytes_per_request",
+				Description: "Average number of bytes sent per request since the server was restarted",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "duration_per_request_ms",
+				Description: "Average time spent handling each request since the server was restarted, in milliseconds",
+				Type:        "float",
+			},
+			// cpu
+			{
+				ColumnName:  "cpu_user",
+				Description: "User CPU time of the server processes, in seconds",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "cpu_system",
+				Description: "System CPU time of the server processes, in seconds",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "cpu_children_user",
+				Description: "User CPU time of exited child processes, in seconds",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "cpu_children_system",
+				Description: "System CPU time of exited child processes, in seconds",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "cpu_load",
+				Description: "Percentage of CPU used by the server since it was restarted",
+				Type:        "float",
+			},
+			// workers
+			{
+				ColumnName:  "busy_workers",
+				Description: "Number of workers handling requests",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "graceful_workers",
+				Description: "Number of workers finishing requests before gracefully stopping",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "idle_workers",
+				Description: "Number of idle workers",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "processes",
+				Description: "Number of child processes",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "stopping_processes",
+				Description: "Number of child processes which are stopping",
+				Type:        "integer",
+			},
+			// connections (event MPM)
+			{
+				ColumnName:  "conns_total",
+				Description: "Total number of connections",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "conns_async_writing",
+				Description: "Number of asynchronous connections writing a response",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "conns_async_keep_alive",
+				Description: "Number of asynchronous connections in keep-alive",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "conns_async_closing",
+				Description: "Number of asynchronous connections closing",
+				Type:        "integer",
+			},
+			// scoreboard
+			{
+				ColumnName:  "scoreboard",
+				Description: "Scoreboard, with a character for the state of each worker slot",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "scoreboard_waiting",
+				Description: "Number of workers waiting for a connection ('_')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_starting",
+				Description: "Number of workers starting up ('S')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_reading",
+				Description: "Number of workers reading a request ('R')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_sending",
+				Description: "Number of workers sending a reply ('W')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_keepalive",
+				Description: "Number of workers in keep-alive ('K')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_dns_lookup",
+				Description: "Number of workers performing a DNS lookup ('D')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_closing",
+				Description: "Number of workers closing a connection ('C')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_logging",
+				Description: "Number of workers logging a request ('L')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_graceful_finishing",
+				Description: "Number of workers gracefully finishing ('G')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_idle_cleanup",
+				Description: "Number of idle workers being cleaned up ('I')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_open_slots",
+				Description: "Number of open slots with no worker ('.')",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "scoreboard_total_slots",
+				Description: "Total number of worker slots in the scoreboard",
+				Type:        "integer",
+			},
+		},
+	}
+}
+
+func (c *ServerStatusTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
+	// ask our CustomTableImpl for the mapper
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	// which sources do we support?
+	return []*table.SourceMetadata[*types.DynamicRow]{
+		{
+			// any artifact source - each artifact is a saved snapshot
+			SourceName: constants.ArtifactSourceIdentifier,
+			Mapper:     mapper,
+			Options: []row_source.RowSourceOption{
+				artifact_source.WithArtifactLoader(newServerStatusLoader()),
+			},
+		},
+		{
+			// poll the status page of each server
+			SourceName: ServerStatusSourceIdentifier,
+			Mapper:     mapper,
+		},
+	}, nil
+}
+
+func (c *ServerStatusTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	ts, ok := row.GetSourceValue("timestamp")
+	if !ok {
+		return nil, error_types.NewRowErrorWithFields([]string{"timestamp"}, []string{})
+	}
+	t, err := helpers.ParseTime(ts)
+	if err != nil {
+		return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
+	}
+	row.OutputColumns[constants.TpTimestamp] = t
+
+	// tp_ips or tp_domains, depending on whether the server is identified by its address or name
+	if server, ok := row.GetSourceValue("server"); ok && server != "" {
+		if addr, err := netip.ParseAddr(strings.Trim(server, "[]")); err == nil {
+			row.OutputColumns[constants.TpIps] = []string{addr.String()}
+		} else {
+			row.OutputColumns[constants.TpDomains] = []string{strings.ToLower(server)}
+		}
+	}
+
+	// now call the base class to do the rest of the enrichment
+	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+}
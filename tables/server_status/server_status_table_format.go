@@ -0,0 +1,68 @@
+package server_status
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ServerStatusTableFormatIdentifier = "apache_server_status"
+
+// ServerStatusTableFormat is the machine readable output of mod_status (server-status?auto), with one
+// "<key>: <value>" line for each value, preceded by the server name in Apache 2.4:
+//
+//	www.example.com
+//	ServerVersion: Apache/2.4.62 (Debian)
+//	ServerMPM: event
+//	...
+//	BusyWorkers: 3
+//	IdleWorkers: 72
+//	Scoreboard: __W_K_____R.......
+//
+// The output cannot be configured, so this format has no arguments.
+type ServerStatusTableFormat struct {
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+}
+
+func NewServerStatusTableFormat() formats.Format {
+	return &ServerStatusTableFormat{}
+}
+
+func (a *ServerStatusTableFormat) Validate() error {
+	return nil
+}
+
+// Identifier returns the format TYPE
+func (a *ServerStatusTableFormat) Identifier() string {
+	return ServerStatusTableFormatIdentifier
+}
+
+// GetName returns the format instance name
+func (a *ServerStatusTableFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *ServerStatusTableFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *ServerStatusTableFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *ServerStatusTableFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &serverStatusMapper{}, nil
+}
+
+// GetRegex returns N/A, as status snapshots are not parsed with a regex
+func (a *ServerStatusTableFormat) GetRegex() (string, error) {
+	return "N/A", nil
+}
+
+func (a *ServerStatusTableFormat) GetProperties() map[string]string {
+	return map[string]string{}
+}
@@ -0,0 +1,46 @@
+package server_status
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const ServerStatusLoaderIdentifier = "apache_server_status_loader"
+
+// serverStatusLoader is a Loader which reads a saved server-status?auto output as a single snapshot,
+// using the modification time of the file as the time of the snapshot
+type serverStatusLoader struct{}
+
+func newServerStatusLoader() *serverStatusLoader {
+	return &serverStatusLoader{}
+}
+
+func (l *serverStatusLoader) Identifier() string {
+	return ServerStatusLoaderIdentifier
+}
+
+// Load implements Loader
+func (l *serverStatusLoader) Load(_ context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	slog.Debug("serverStatusLoader Load", "path", info.LocalName)
+
+	stat, err := os.Stat(info.LocalName)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", info.LocalName, err)
+	}
+	data, err := os.ReadFile(info.LocalName)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", info.LocalName, err)
+	}
+
+	go func() {
+		dataChan <- &types.RowData{
+			Data: &serverStatusSnapshot{text: string(data), time: stat.ModTime()},
+		}
+		close(dataChan)
+	}()
+	return nil
+}
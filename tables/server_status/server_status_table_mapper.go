@@ -0,0 +1,231 @@
+package server_status
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// statusTimeLayout is the layout of the CurrentTime and RestartTime values (Apache's DEFAULT_TIME_FORMAT)
+const statusTimeLayout = "Monday, 02-Jan-2006 15:04:05 MST"
+
+// serverStatusSnapshot is a server-status?auto output, read from a saved file by the serverStatusLoader
+// or fetched by the ServerStatusSource
+type serverStatusSnapshot struct {
+	text string
+	// the time the snapshot was taken - the time it was fetched, or the modification time of a saved snapshot
+	time time.Time
+	// the URL the snapshot was fetched from, if it was polled
+	url string
+}
+
+// serverStatusFieldMap maps each server-status?auto key to its column
+// keys which are not in the map (e.g. the TLS session cache statistics) are ignored
+var serverStatusFieldMap = map[string]string{
+	"ServerVersion":                "server_version",
+	"ServerMPM":                    "server_mpm",
+	"Server Built":                 "server_built",
+	"CurrentTime":                  "timestamp",
+	"RestartTime":                  "restart_time",
+	"ParentServerConfigGeneration": "parent_server_config_generation",
+	"ParentServerMPMGeneration":    "parent_server_mpm_generation",
+	"ServerUptimeSeconds":          "uptime_seconds",
+	"Uptime":                       "uptime_seconds",
+	"Load1":                        "load_1",
+	"Load5":                        "load_5",
+	"Load15":                       "load_15",
+	"Total Accesses":               "total_accesses",
+	"Total kBytes":                 "total_kbytes",
+	"Total Duration":               "total_duration_ms",
+	"CPUUser":                      "cpu_user",
+	"CPUSystem":                    "cpu_system",
+	"CPUChildrenUser":              "cpu_children_user",
+	"CPUChildrenSystem":            "cpu_children_system",
+	"CPULoad":                      "cpu_load",
+	"ReqPerSec":                    "requests_per_sec",
+	"BytesPerSec":                  "bytes_per_sec",
+	"BytesPerReq":                  "bytes_per_request",
+	"DurationPerReq":               "duration_per_request_ms",
+	"BusyWorkers":                  "busy_workers",
+	"GracefulWorkers":              "graceful_workers",
+	"IdleWorkers":                  "idle_workers",
+	"Processes":                    "processes",
+	"Stopping":                     "stopping_processes",
+	"ConnsTotal":                   "conns_total",
+	"ConnsAsyncWriting":            "conns_async_writing",
+	"ConnsAsyncKeepAlive":          "conns_async_keep_alive",
+	"ConnsAsyncClosing":            "conns_async_closing",
+	"Scoreboard":                   "scoreboard",
+}
+
+// serverStatusFloatColumns are logged with a leading '.' for values below 1 (e.g. ".0476"), so are normalised
+var serverStatusFloatColumns = map[string]bool{
+	"load_1":                  true,
+	"load_5":                  true,
+	"load_15":                 true,
+	"cpu_user":                true,
+	"cpu_system":              true,
+	"cpu_children_user":       true,
+	"cpu_children_system":     true,
+	"cpu_load":                true,
+	"requests_per_sec":        true,
+	"bytes_per_sec":           true,
+	"bytes_per_request":       true,
+	"duration_per_request_ms": true,
+}
+
+// scoreboardColumns maps each scoreboard key to the column counting the workers in that state
+var scoreboardColumns = map[rune]string{
+	'_': "scoreboard_waiting",
+	'S': "scoreboard_starting",
+	'R': "scoreboard_reading",
+	'W': "scoreboard_sending",
+	'K': "scoreboard_keepalive",
+	'D': "scoreboard_dns_lookup",
+	'C': "scoreboard_closing",
+	'L': "scoreboard_logging",
+	'G': "scoreboard_graceful_finishing",
+	'I': "scoreboard_idle_cleanup",
+	'.': "scoreboard_open_slots",
+}
+
+// serverStatusMapper maps a server-status?auto snapshot to a row
+type serverStatusMapper struct{}
+
+func (m *serverStatusMapper) Identifier() string {
+	return "apache_server_status_mapper"
+}
+
+func (m *serverStatusMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	snapshot, ok := a.(*serverStatusSnapshot)
+	if !ok {
+		return nil, fmt.Errorf("expected *serverStatusSnapshot, got %T", a)
+	}
+
+	fields, err := parseServerStatus(snapshot.text)
+	if err != nil {
+		return nil, err
+	}
+	applySnapshotTime(fields, snapshot.time)
+
+	if snapshot.url != "" {
+		fields["status_url"] = snapshot.url
+		// the server name is only included in the output by Apache 2.4
+		if _, ok := fields["server"]; !ok {
+			if u, err := url.Parse(snapshot.url); err == nil && u.Hostname() != "" {
+				fields["server"] = u.Hostname()
+			}
+		}
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
+
+// parseServerStatus parses the lines of a server-status?auto output into fields keyed by column
+func parseServerStatus(text string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.HasPrefix(value, " ") {
+			// Apache 2.4 starts the output with the server name (which may be an IPv6 address, so is identified by
+			// the missing space after any colon) - any other line without a "<key>: " prefix is a section heading
+			// added by another module (e.g. TLSSessionCacheStatus)
+			if i == 0 {
+				fields["server"] = line
+			}
+			continue
+		}
+		column, ok := serverStatusFieldMap[key]
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if serverStatusFloatColumns[column] {
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				value = strconv.FormatFloat(f, 'f', -1, 64)
+			}
+		}
+		fields[column] = value
+	}
+	if _, ok := fields["scoreboard"]; !ok {
+		if _, ok := fields["busy_workers"]; !ok {
+			return nil, fmt.Errorf("error parsing server status: no worker counts or scoreboard found")
+		}
+	}
+
+	if scoreboard, ok := fields["scoreboard"]; ok {
+		counts := make(map[string]int)
+		for _, column := range scoreboardColumns {
+			counts[column] = 0
+		}
+		for _, c := range scoreboard {
+			if column, ok := scoreboardColumns[c]; ok {
+				counts[column]++
+			}
+		}
+		for column, count := range counts {
+			fields[column] = strconv.Itoa(count)
+		}
+		fields["scoreboard_total_slots"] = strconv.Itoa(len(scoreboard))
+	}
+	return fields, nil
+}
+
+// applySnapshotTime converts the CurrentTime and RestartTime values to RFC 3339
+//
+// Apache logs these times in the server's local time zone, with the zone abbreviation. Only UTC, GMT and the
+// local time zone of the plugin can be resolved from an abbreviation, so for any other zone the time the snapshot
+// was taken is used instead, and the restart time is calculated from the uptime.
+func applySnapshotTime(fields map[string]string, snapshotTime time.Time) {
+	current, currentOk := parseStatusTime(fields["timestamp"])
+	restart, restartOk := parseStatusTime(fields["restart_time"])
+	delete(fields, "timestamp")
+	delete(fields, "restart_time")
+
+	if !currentOk && !snapshotTime.IsZero() {
+		current, currentOk = snapshotTime.UTC(), true
+	}
+	if currentOk {
+		fields["timestamp"] = current.Format(time.RFC3339)
+	}
+
+	if !restartOk && currentOk {
+		if uptime, err := strconv.ParseInt(fields["uptime_seconds"], 10, 64); err == nil {
+			restart, restartOk = current.Add(-time.Duration(uptime)*time.Second), true
+		}
+	}
+	if restartOk {
+		fields["restart_time"] = restart.Format(time.RFC3339)
+	}
+}
+
+// parseStatusTime parses a CurrentTime or RestartTime value, if its time zone can be resolved
+func parseStatusTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(statusTimeLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// an unknown zone abbreviation is parsed as a fabricated zone with a zero offset
+	zone, offset := t.Zone()
+	if t.Location() != time.UTC && t.Location() != time.Local && !(offset == 0 && (zone == "UTC" || zone == "GMT")) {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
@@ -0,0 +1,15 @@
+package server_status
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+)
+
+// DefaultServerStatusFormat is the machine readable mod_status output
+var DefaultServerStatusFormat = &ServerStatusTableFormat{
+	Name:        "auto",
+	Description: "The machine readable mod_status output (server-status?auto).",
+}
+
+var ServerStatusTableFormatPresets = []formats.Format{
+	DefaultServerStatusFormat,
+}
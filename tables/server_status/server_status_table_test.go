@@ -0,0 +1,150 @@
+package server_status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-apache/internal/tabletest"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+)
+
+const apache24Status = `www.example.com
+ServerVersion: Apache/2.4.62 (Debian)
+ServerMPM: event
+Server Built: 2024-07-17T18:57:09
+CurrentTime: Monday, 24-Feb-2025 12:34:56 UTC
+RestartTime: Monday, 24-Feb-2025 08:00:00 UTC
+ParentServerConfigGeneration: 3
+ParentServerMPMGeneration: 2
+ServerUptimeSeconds: 16496
+ServerUptime: 4 hours 34 minutes 56 seconds
+Load1: 0.42
+Load5: 0.35
+Load15: 0.30
+Total Accesses: 785
+Total kBytes: 5123
+Total Duration: 2317
+CPUUser: 1.2
+CPUSystem: .84
+CPUChildrenUser: 0
+CPUChildrenSystem: 0
+CPULoad: .0123666
+Uptime: 16496
+ReqPerSec: .0475873
+BytesPerSec: 318.014
+BytesPerReq: 6682.74
+DurationPerReq: 2.95159
+BusyWorkers: 3
+GracefulWorkers: 0
+IdleWorkers: 72
+Processes: 3
+Stopping: 0
+ConnsTotal: 4
+ConnsAsyncWriting: 0
+ConnsAsyncKeepAlive: 1
+ConnsAsyncClosing: 0
+Scoreboard: __W_K_____R____G...........
+TLSSessionCacheStatus
+CacheType: SHMCB
+CacheSharedMemory: 512000
+`
+
+const apache22Status = "Total Accesses: 1021\r\nTotal kBytes: 4092\r\nCPULoad: .00235\r\nUptime: 86400\r\nReqPerSec: .0118171\r\nBytesPerSec: 48.4978\r\nBytesPerReq: 4104.03\r\nBusyWorkers: 1\r\nIdleWorkers: 7\r\nScoreboard: _W______........\r\n"
+
+func Test_ServerStatusTable_MapAndEnrichRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot *serverStatusSnapshot
+		want     map[string]any
+		wantErr  bool
+	}{
+		{
+			name:     "Apache 2.4 with CurrentTime",
+			snapshot: &serverStatusSnapshot{text: apache24Status, time: time.Date(2025, 2, 24, 12, 35, 0, 0, time.UTC)},
+			want: map[string]any{
+				"server":                        "www.example.com",
+				"timestamp":                     time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC),
+				"restart_time":                  time.Date(2025, 2, 24, 8, 0, 0, 0, time.UTC),
+				"server_version":                "Apache/2.4.62 (Debian)",
+				"server_mpm":                    "event",
+				"uptime_seconds":                "16496",
+				"total_accesses":                "785",
+				"cpu_system":                    "0.84",
+				"requests_per_sec":              "0.0475873",
+				"busy_workers":                  "3",
+				"idle_workers":                  "72",
+				"conns_async_keep_alive":        "1",
+				"scoreboard_waiting":            "12",
+				"scoreboard_sending":            "1",
+				"scoreboard_keepalive":          "1",
+				"scoreboard_reading":            "1",
+				"scoreboard_graceful_finishing": "1",
+				"scoreboard_open_slots":         "11",
+				"scoreboard_dns_lookup":         "0",
+				"scoreboard_total_slots":        "27",
+				constants.TpDomains:             []string{"www.example.com"},
+				// keys without a column are ignored
+				"cache_type":    nil,
+				"ServerUptime":  nil,
+				"CacheType":     nil,
+				constants.TpIps: nil,
+			},
+		},
+		{
+			name:     "Apache 2.2 polled from a URL",
+			snapshot: &serverStatusSnapshot{text: apache22Status, time: time.Date(2025, 2, 24, 12, 35, 0, 0, time.UTC), url: "http://10.0.0.5:8080/server-status?auto"},
+			want: map[string]any{
+				"server":                 "10.0.0.5",
+				"status_url":             "http://10.0.0.5:8080/server-status?auto",
+				"timestamp":              time.Date(2025, 2, 24, 12, 35, 0, 0, time.UTC),
+				"restart_time":           time.Date(2025, 2, 23, 12, 35, 0, 0, time.UTC),
+				"uptime_seconds":         "86400",
+				"cpu_load":               "0.00235",
+				"scoreboard_waiting":     "7",
+				"scoreboard_total_slots": "16",
+				constants.TpIps:          []string{"10.0.0.5"},
+				constants.TpDomains:      nil,
+			},
+		},
+		{
+			name: "CurrentTime in a zone which cannot be resolved uses the snapshot time",
+			snapshot: &serverStatusSnapshot{
+				text: "::1\nCurrentTime: Monday, 24-Feb-2025 13:34:56 XYZ\nServerUptimeSeconds: 60\nBusyWorkers: 1\nIdleWorkers: 1\n",
+				time: time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC),
+			},
+			want: map[string]any{
+				"server":        "::1",
+				"timestamp":     time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC),
+				"restart_time":  time.Date(2025, 2, 24, 12, 33, 56, 0, time.UTC),
+				"scoreboard":    nil,
+				constants.TpIps: []string{"::1"},
+			},
+		},
+		{
+			name:     "not a status page",
+			snapshot: &serverStatusSnapshot{text: "<html><body>Not Found</body></html>\n", time: time.Now()},
+			wantErr:  true,
+		},
+		{
+			name:     "missing timestamp",
+			snapshot: &serverStatusSnapshot{text: "::1\nServerUptimeSeconds: 60\nBusyWorkers: 1\nIdleWorkers: 1\n"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := tabletest.MapAndEnrich(t, &ServerStatusTable{}, DefaultServerStatusFormat, tt.snapshot)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tabletest.AssertColumns(t, row, tt.want)
+		})
+	}
+}
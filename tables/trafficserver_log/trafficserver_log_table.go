@@ -0,0 +1,360 @@
+package trafficserver_log
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-apache/internal/dynamicrow"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/error_types"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/table"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const TrafficServerLogTableIdentifier = "apache_trafficserver_log"
+const TrafficServerLogTableNilValue = "-"
+
+// TrafficServerLogTable - table for Apache Traffic Server access logs
+type TrafficServerLogTable struct {
+	table.CustomTableImpl
+}
+
+func (c *TrafficServerLogTable) Identifier() string {
+	return TrafficServerLogTableIdentifier
+}
+
+func (c *TrafficServerLogTable) GetDefaultFormat() formats.Format {
+	return DefaultTrafficServerLogFormat
+}
+
+func (c *TrafficServerLogTable) GetTableDefinition() *schema.TableSchema {
+	return &schema.TableSchema{
+		Name: TrafficServerLogTableIdentifier,
+		Columns: []*schema.ColumnSchema{
+			{
+				ColumnName: "tp_source_ip",
+				SourceName: "client_ip",
+			},
+			// client
+			{
+				ColumnName:  "timestamp",
+				Description: "Time the client request was received",
+				Type:        "timestamp",
+			},
+			{
+				ColumnName:  "client_ip",
+				Description: "IP address of the client",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "client_port",
+				Description: "Port of the client",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "client_auth_user",
+				Description: "User name the client authenticated as",
+				Type:        "varchar",
+			},
+			// request
+			{
+				ColumnName:  "request_line",
+				Description: "Request line sent by the client (method, URL and HTTP version)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_method",
+				Description: "HTTP method of the client request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_url",
+				Description: "URL of the client request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_path",
+				Description: "Path of the client request URL",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "request_scheme",
+				Description: "Scheme of the client request URL",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_version",
+				Description: "HTTP version of the client request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "proxy_request_url",
+				Description: "URL of the request sent by the proxy, after remapping",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_referer",
+				Description: "Referer header of the client request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_user_agent",
+				Description: "User-Agent header of the client request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_host",
+				Description: "Host header of the client request",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "http_x_forwarded_for",
+				Description: "X-Forwarded-For header of the client request",
+				Type:        "varchar",
+			},
+			// response
+			{
+				ColumnName:  "status",
+				Description: "HTTP status code of the proxy response to the client",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "content_type",
+				Description: "Content type of the proxy response",
+				Type:        "varchar",
+			},
+			// cache
+			{
+				ColumnName:  "cache_result_code",
+				Description: "Cache result code (e.g. TCP_HIT, TCP_MISS, TCP_REFRESH_HIT, ERR_CLIENT_ABORT)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "cache_result_subcode",
+				Description: "Cache result sub-code, giving more detail about the cache result code",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "cache_hit_miss",
+				Description: "Cache hit-miss status",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "is_cache_hit",
+				Description: "True if the response was served from the cache",
+				Type:        "boolean",
+			},
+			{
+				ColumnName:  "hierarchy_route",
+				Description: "Route the proxy used to retrieve the object (e.g. DIRECT, PARENT_HIT, NONE)",
+				Type:        "varchar",
+			},
+			// origin
+			{
+				ColumnName:  "origin_host",
+				Description: "Host name of the origin server",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "origin_ip",
+				Description: "IP address of the origin server",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "origin_status",
+				Description: "HTTP status code of the origin server response",
+				Type:        "integer",
+			},
+			// timing
+			{
+				ColumnName:  "time_to_serve",
+				Description: "Time taken to serve the request in seconds",
+				Type:        "integer",
+			},
+			{
+				ColumnName:  "time_to_serve_ms",
+				Description: "Time taken to serve the request in milliseconds",
+				Type:        "float",
+			},
+			{
+				ColumnName:  "origin_time_ms",
+				Description: "Time spent with the origin server in milliseconds",
+				Type:        "float",
+			},
+			// bytes
+			{
+				ColumnName:  "bytes_sent",
+				Description: "Number of bytes sent to the client, including headers",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "response_content_length",
+				Description: "Content length of the proxy response to the client",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "response_header_length",
+				Description: "Header length of the proxy response to the client",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "origin_content_length",
+				Description: "Content length of the origin server response",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "origin_header_length",
+				Description: "Header length of the origin server response",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "request_content_length",
+				Description: "Content length of the client request",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "request_header_length",
+				Description: "Header length of the client request",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "proxy_request_content_length",
+				Description: "Content length of the proxy request to the origin server",
+				Type:        "bigint",
+			},
+			{
+				ColumnName:  "proxy_request_header_length",
+				Description: "Header length of the proxy request to the origin server",
+				Type:        "bigint",
+			},
+			// transaction
+			{
+				ColumnName:  "client_finish_status",
+				Description: "How the client transaction finished (FIN, INTR or TIMEOUT)",
+				Type:        "varchar",
+			},
+			{
+				ColumnName:  "proxy_finish_status",
+				Description: "How the proxy transaction with the origin server finished (FIN, INTR or TIMEOUT)",
+				Type:        "varchar",
+			},
+		},
+		NullIf: TrafficServerLogTableNilValue, // default null value
+	}
+}
+
+func (c *TrafficServerLogTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.DynamicRow], error) {
+	// ask our CustomTableImpl for the mapper
+	mapper, err := c.Format.GetMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	// which source do we support?
+	return []*table.SourceMetadata[*types.DynamicRow]{
+		{
+			// any artifact source
+			SourceName: constants.ArtifactSourceIdentifier,
+			Mapper:     mapper,
+			Options: []row_source.RowSourceOption{
+				artifact_source.WithRowPerLine(),
+			},
+		},
+	}, nil
+}
+
+func (c *TrafficServerLogTable) EnrichRow(row *types.DynamicRow, sourceEnrichmentFields schema.SourceEnrichment) (*types.DynamicRow, error) {
+	if ts, ok := row.GetSourceValue("timestamp"); ok && ts != TrafficServerLogTableNilValue {
+		t, err := helpers.ParseTime(ts)
+		if err != nil {
+			return nil, error_types.NewRowErrorWithFields([]string{}, []string{"timestamp"})
+		}
+		row.OutputColumns[constants.TpTimestamp] = t
+	}
+
+	// the request line is split into the method, URL and version, if they were not logged separately
+	if line, ok := dynamicrow.SourceValue(row, "request_line"); ok {
+		parts := strings.Fields(line)
+		for i, column := range []string{"request_method", "request_url", "http_version"} {
+			if _, ok := dynamicrow.SourceValue(row, column); !ok && i < len(parts) {
+				row.OutputColumns[column] = parts[i]
+			}
+		}
+	}
+
+	// is_cache_hit
+	if code, ok := dynamicrow.SourceValue(row, "cache_result_code"); ok {
+		row.OutputColumns["is_cache_hit"] = strings.Contains(code, "HIT")
+	}
+
+	// tp_ips
+	var ips []string
+	for _, column := range []string{"client_ip", "origin_ip"} {
+		if value, ok := dynamicrow.SourceValue(row, column); ok {
+			if addr, err := netip.ParseAddr(value); err == nil && !slices.Contains(ips, addr.String()) {
+				ips = append(ips, addr.String())
+			}
+		}
+	}
+	if len(ips) > 0 {
+		row.OutputColumns[constants.TpIps] = ips
+	}
+
+	// tp_domains - the hosts of the requested URLs and the origin server
+	var domains []string
+	for _, domain := range []string{urlHost(row, "request_url"), urlHost(row, "proxy_request_url"), hostValue(row, "http_host"), hostValue(row, "origin_host")} {
+		if domain != "" && !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) > 0 {
+		row.OutputColumns[constants.TpDomains] = domains
+	}
+
+	// tp_usernames
+	if user, ok := dynamicrow.SourceValue(row, "client_auth_user"); ok {
+		row.OutputColumns[constants.TpUsernames] = []string{user}
+	}
+
+	// now call the base class to do the rest of the enrichment
+	return c.CustomTableImpl.EnrichRow(row, sourceEnrichmentFields)
+}
+
+// urlHost returns the lower case host name of the URL in a source field, if it is an absolute URL
+func urlHost(row *types.DynamicRow, field string) string {
+	value, ok := dynamicrow.SourceValue(row, field)
+	if !ok && field == "request_url" {
+		value, ok = row.OutputColumns[field].(string)
+	}
+	if !ok {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// hostValue returns the lower case host name in a source field, if it is a host name rather than an IP address
+func hostValue(row *types.DynamicRow, field string) string {
+	value, ok := dynamicrow.SourceValue(row, field)
+	if !ok {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	if _, err := netip.ParseAddr(value); err == nil {
+		return ""
+	}
+	return strings.ToLower(value)
+}
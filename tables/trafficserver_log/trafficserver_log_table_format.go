@@ -0,0 +1,167 @@
+package trafficserver_log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// trafficServerFieldMap is the logging.yaml log field table - the regex group each field is captured in, keyed by
+// field (the header fields with a dedicated column are keyed by {header}field)
+var trafficServerFieldMap = map[string]string{
+	"chi":                  "client_ip",                    // client host IP address
+	"chp":                  "client_port",                  // client host port
+	"caun":                 "client_auth_user",             // client authenticated user name
+	"cqtq":                 "timestamp_squid",              // client request time in seconds since the epoch, with milliseconds
+	"cqts":                 "timestamp_sec",                // client request time in seconds since the epoch
+	"cqth":                 "timestamp_hex",                // client request time in seconds since the epoch, in hexadecimal
+	"cqtn":                 "timestamp",                    // client request time in the Netscape format
+	"cqtd":                 "timestamp_date",               // client request date (yyyy-mm-dd)
+	"cqtt":                 "timestamp_time",               // client request time (hh:mm:ss)
+	"cqtx":                 "request_line",                 // client request line (method, URL and version)
+	"cqhm":                 "request_method",               // client request method
+	"cqu":                  "request_url",                  // client request URL
+	"cquc":                 "request_url",                  // client request URL (canonical)
+	"cqup":                 "request_path",                 // client request URL path
+	"cqus":                 "request_scheme",               // client request URL scheme
+	"cqhv":                 "http_version",                 // client request HTTP version
+	"pqu":                  "proxy_request_url",            // proxy request URL (after remapping)
+	"pquc":                 "proxy_request_url",            // proxy request URL (canonical)
+	"pssc":                 "status",                       // proxy response status code
+	"sssc":                 "origin_status",                // origin server response status code
+	"crc":                  "cache_result_code",            // cache result code (e.g. TCP_HIT, TCP_MISS)
+	"crsc":                 "cache_result_subcode",         // cache result sub-code
+	"chm":                  "cache_hit_miss",               // cache hit-miss status
+	"phr":                  "hierarchy_route",              // proxy hierarchy route (e.g. DIRECT, PARENT_HIT)
+	"shn":                  "origin_host",                  // origin server host name
+	"shi":                  "origin_ip",                    // origin server IP address
+	"psct":                 "content_type",                 // proxy response content type
+	"ttms":                 "time_to_serve_ms",             // time to serve the request in milliseconds
+	"ttmsf":                "time_to_serve_ms",             // time to serve the request in milliseconds, with fractions
+	"tts":                  "time_to_serve",                // time to serve the request in seconds
+	"stms":                 "origin_time_ms",               // time spent with the origin server in milliseconds
+	"stmsf":                "origin_time_ms",               // time spent with the origin server in milliseconds, with fractions
+	"psql":                 "bytes_sent",                   // bytes sent to the client, including headers
+	"pscl":                 "response_content_length",      // proxy response content length
+	"pshl":                 "response_header_length",       // proxy response header length
+	"sscl":                 "origin_content_length",        // origin server response content length
+	"sshl":                 "origin_header_length",         // origin server response header length
+	"cqcl":                 "request_content_length",       // client request content length
+	"cqhl":                 "request_header_length",        // client request header length
+	"pqcl":                 "proxy_request_content_length", // proxy request content length
+	"pqhl":                 "proxy_request_header_length",  // proxy request header length
+	"cfsc":                 "client_finish_status",         // client finish status code (FIN, INTR, TIMEOUT)
+	"pfsc":                 "proxy_finish_status",          // proxy finish status code (FIN, INTR, TIMEOUT)
+	"{Referer}cqh":         "http_referer",                 // Referer client request header
+	"{User-Agent}cqh":      "http_user_agent",              // User-Agent client request header
+	"{Host}cqh":            "http_host",                    // Host client request header
+	"{X-Forwarded-For}cqh": "http_x_forwarded_for",         // X-Forwarded-For client request header
+}
+
+// trafficServerFieldRegex matches a log field - %< followed by an optional {header} and the field name, then >
+var trafficServerFieldRegex = regexp.MustCompile(`%<(\{[^}]*\})?([a-zA-Z0-9-]+)>`)
+
+type TrafficServerLogTableFormat struct {
+	// the name of this format instance
+	Name string `hcl:"name,label"`
+	// Description of the format
+	Description string `hcl:"description,optional"`
+	// the layout of the log line, using the logging.yaml format syntax
+	Layout string `hcl:"layout"`
+}
+
+func NewTrafficServerLogTableFormat() formats.Format {
+	return &TrafficServerLogTableFormat{}
+}
+
+func (a *TrafficServerLogTableFormat) Validate() error {
+	return nil
+}
+
+// Identifier returns the format TYPE
+func (a *TrafficServerLogTableFormat) Identifier() string {
+	// format name is same as table name
+	return TrafficServerLogTableIdentifier
+}
+
+// GetName returns the format instance name
+func (a *TrafficServerLogTableFormat) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *TrafficServerLogTableFormat) SetName(name string) {
+	a.Name = name
+}
+
+func (a *TrafficServerLogTableFormat) GetDescription() string {
+	return a.Description
+}
+
+func (a *TrafficServerLogTableFormat) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	// convert the layout to a regex
+	regex, err := a.GetRegex()
+	if err != nil {
+		return nil, err
+	}
+	return newTrafficServerLogMapper(regex)
+}
+
+// GetRegex converts the layout to a regex
+//
+// Traffic Server does not escape the values it logs, so a value wrapped in double quotes (e.g. "%<cqtx>") or square
+// brackets (e.g. [%<cqtn>]) is matched up to the closing quote or bracket, and any other value up to the next space.
+func (a *TrafficServerLogTableFormat) GetRegex() (string, error) {
+	layout := a.Layout
+
+	var regex strings.Builder
+	groups := make(map[string]bool)
+	last := 0
+	for _, loc := range trafficServerFieldRegex.FindAllStringSubmatchIndex(layout, -1) {
+		// escape the literal text preceding the field
+		regex.WriteString(regexp.QuoteMeta(layout[last:loc[0]]))
+		last = loc[1]
+
+		key := layout[loc[4]:loc[5]]
+		if loc[2] != -1 {
+			key = layout[loc[2]:loc[3]] + key
+		}
+		group, ok := trafficServerFieldMap[key]
+		if !ok {
+			return "", fmt.Errorf("unsupported field in format: %s", layout[loc[0]:loc[1]])
+		}
+
+		value := `[^ ]*`
+		if loc[0] > 0 {
+			switch layout[loc[0]-1] {
+			case '"':
+				value = `[^"]*`
+			case '[':
+				value = `[^\]]*`
+			}
+		}
+		// a field may be logged more than once, but may only be captured once
+		if groups[group] {
+			regex.WriteString(value)
+			continue
+		}
+		groups[group] = true
+		regex.WriteString(fmt.Sprintf(`(?P<%s>%s)`, group, value))
+	}
+	regex.WriteString(regexp.QuoteMeta(layout[last:]))
+
+	if regex.Len() == 0 {
+		return "", nil
+	}
+	return "^" + regex.String() + "$", nil
+}
+
+func (a *TrafficServerLogTableFormat) GetProperties() map[string]string {
+	return map[string]string{
+		"layout": a.Layout,
+	}
+}
@@ -0,0 +1,130 @@
+package trafficserver_log
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_TrafficServerLogTableFormat_GetMapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		logLine string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "Squid format cache miss",
+			layout:  DefaultTrafficServerLogFormat.Layout,
+			logLine: `1740400496.123 12 192.168.1.1 TCP_MISS/200 5123 GET http://www.example.com/index.html - DIRECT/www.example.com text/html`,
+			want: map[string]string{
+				"timestamp":         "2025-02-24T12:34:56.123Z",
+				"time_to_serve_ms":  "12",
+				"client_ip":         "192.168.1.1",
+				"cache_result_code": "TCP_MISS",
+				"status":            "200",
+				"bytes_sent":        "5123",
+				"request_method":    "GET",
+				"request_url":       "http://www.example.com/index.html",
+				"client_auth_user":  "-",
+				"hierarchy_route":   "DIRECT",
+				"origin_host":       "www.example.com",
+				"content_type":      "text/html",
+			},
+		},
+		{
+			name:    "Squid format cache hit",
+			layout:  DefaultTrafficServerLogFormat.Layout,
+			logLine: `1740400497 0 2001:db8::1 TCP_MEM_HIT/200 24831 GET https://cdn.example.com/assets/app.js - NONE/- application/javascript`,
+			want: map[string]string{
+				"timestamp":         "2025-02-24T12:34:57Z",
+				"client_ip":         "2001:db8::1",
+				"cache_result_code": "TCP_MEM_HIT",
+				"hierarchy_route":   "NONE",
+				"origin_host":       "-",
+			},
+		},
+		{
+			name:    "Extended2 format",
+			layout:  TrafficServerLogTableFormatPresets[3].(*TrafficServerLogTableFormat).Layout,
+			logLine: `192.168.1.1 - alice [24/Feb/2025:12:34:56 -0000] "GET http://www.example.com/api/items?page=2 HTTP/1.1" 200 1532 200 1532 0 0 412 289 498 276 0 DIRECT FIN FIN TCP_REFRESH_MISS`,
+			want: map[string]string{
+				"timestamp":                    "24/Feb/2025:12:34:56 -0000",
+				"client_auth_user":             "alice",
+				"request_line":                 "GET http://www.example.com/api/items?page=2 HTTP/1.1",
+				"status":                       "200",
+				"response_content_length":      "1532",
+				"origin_status":                "200",
+				"origin_content_length":        "1532",
+				"request_content_length":       "0",
+				"proxy_request_content_length": "0",
+				"request_header_length":        "412",
+				"response_header_length":       "289",
+				"proxy_request_header_length":  "498",
+				"origin_header_length":         "276",
+				"time_to_serve":                "0",
+				"client_finish_status":         "FIN",
+				"proxy_finish_status":          "FIN",
+				"cache_result_code":            "TCP_REFRESH_MISS",
+			},
+		},
+		{
+			name:    "Custom format with date, time, headers and origin timing",
+			layout:  `%<cqtd> %<cqtt> %<chi>:%<chp> %<cqhm> %<cqup> %<pssc> %<crc> %<stms> %<ttmsf> "%<{User-Agent}cqh>" %<shi>`,
+			logLine: `2025-02-24 12:34:56 192.168.1.1:51234 POST /api/orders 201 TCP_MISS 85 91.412 "Mozilla/5.0 (X11; Linux x86_64)" 10.0.0.8`,
+			want: map[string]string{
+				"timestamp":        "2025-02-24 12:34:56",
+				"client_port":      "51234",
+				"request_path":     "/api/orders",
+				"origin_time_ms":   "85",
+				"time_to_serve_ms": "91.412",
+				"http_user_agent":  "Mozilla/5.0 (X11; Linux x86_64)",
+				"origin_ip":        "10.0.0.8",
+			},
+		},
+		{
+			name:    "Hex time",
+			layout:  `%<cqth> %<chi> %<pssc>`,
+			logLine: `67bc6770 192.168.1.1 200`,
+			want: map[string]string{
+				"timestamp": "2025-02-24T12:34:56Z",
+			},
+		},
+		{
+			name:    "Unsupported field",
+			layout:  `%<cqtq> %<xyzzy>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := &TrafficServerLogTableFormat{Name: "test", Layout: tt.layout}
+			mapper, err := format.GetMapper()
+			if err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("failed to get mapper: %v", err)
+			}
+			if tt.wantErr {
+				t.Fatalf("expected error")
+			}
+
+			row, err := mapper.Map(context.Background(), tt.logLine)
+			if err != nil {
+				t.Fatalf("unexpected mapping error: %v", err)
+			}
+			for k, want := range tt.want {
+				if got, _ := row.GetSourceValue(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+			for _, k := range []string{"timestamp_squid", "timestamp_sec", "timestamp_hex", "timestamp_date", "timestamp_time"} {
+				if _, ok := row.GetSourceValue(k); ok {
+					t.Errorf("%s should be removed from the row", k)
+				}
+			}
+		})
+	}
+}
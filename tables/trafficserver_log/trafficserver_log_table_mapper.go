@@ -0,0 +1,117 @@
+package trafficserver_log
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// trafficServerLogMapper maps a log line to a row using a regex, then converts the client request time to a
+// timestamp which can be parsed (Traffic Server logs the time in several formats, e.g. squid's seconds.milliseconds)
+type trafficServerLogMapper struct {
+	re *regexp.Regexp
+}
+
+func newTrafficServerLogMapper(pattern string) (*trafficServerLogMapper, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling regex pattern: %w", err)
+	}
+	return &trafficServerLogMapper{re: re}, nil
+}
+
+func (m *trafficServerLogMapper) Identifier() string {
+	return "apache_trafficserver_log_mapper"
+}
+
+func (m *trafficServerLogMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	input, ok := a.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	match := m.re.FindStringSubmatch(input)
+	if match == nil {
+		return nil, fmt.Errorf("error parsing log line: failed to match regex pattern %s", m.re.String())
+	}
+	fields := make(map[string]string)
+	for i, name := range m.re.SubexpNames() {
+		// skip index 0, which is the full match
+		if i != 0 && name != "" {
+			fields[name] = match[i]
+		}
+	}
+	applyTimestamp(fields)
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from map: %w", err)
+	}
+	return row, nil
+}
+
+// applyTimestamp populates the timestamp field from the client request time, if it was not logged in the Netscape
+// format (%<cqtn>), and removes the fields used to capture the other formats
+//
+// Values which cannot be converted are left in the timestamp field, so they fail timestamp parsing.
+func applyTimestamp(fields map[string]string) {
+	date, hasDate := fields["timestamp_date"]
+	clock, hasClock := fields["timestamp_time"]
+	squid, hasSquid := fields["timestamp_squid"]
+	sec, hasSec := fields["timestamp_sec"]
+	hex, hasHex := fields["timestamp_hex"]
+	for _, field := range []string{"timestamp_date", "timestamp_time", "timestamp_squid", "timestamp_sec", "timestamp_hex"} {
+		delete(fields, field)
+	}
+	if _, ok := fields["timestamp"]; ok {
+		return
+	}
+
+	switch {
+	case hasSquid:
+		fields["timestamp"] = squid
+		if t, ok := parseSquidTime(squid); ok {
+			fields["timestamp"] = t.Format(time.RFC3339Nano)
+		}
+	case hasSec:
+		fields["timestamp"] = sec
+		if n, err := strconv.ParseInt(sec, 10, 64); err == nil {
+			fields["timestamp"] = time.Unix(n, 0).UTC().Format(time.RFC3339Nano)
+		}
+	case hasHex:
+		fields["timestamp"] = hex
+		if n, err := strconv.ParseInt(hex, 16, 64); err == nil {
+			fields["timestamp"] = time.Unix(n, 0).UTC().Format(time.RFC3339Nano)
+		}
+	case hasDate && hasClock:
+		fields["timestamp"] = date + " " + clock
+	}
+}
+
+// parseSquidTime parses a time logged in the squid format - seconds since the epoch, with milliseconds
+// (e.g. 1740400496.123)
+func parseSquidTime(value string) (time.Time, bool) {
+	secs, frac, _ := strings.Cut(value, ".")
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var nanos int64
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		n, err := strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		nanos = n
+	}
+	return time.Unix(s, nanos).UTC(), true
+}
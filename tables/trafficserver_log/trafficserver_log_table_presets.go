@@ -0,0 +1,31 @@
+package trafficserver_log
+
+import (
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+)
+
+// DefaultTrafficServerLogFormat is the squid format, used for the default squid.log
+var DefaultTrafficServerLogFormat = &TrafficServerLogTableFormat{
+	Name:        "squid",
+	Description: "Traffic Server squid format.",
+	Layout:      `%<cqtq> %<ttms> %<chi> %<crc>/%<pssc> %<psql> %<cqhm> %<cquc> %<caun> %<phr>/%<shn> %<psct>`,
+}
+
+var TrafficServerLogTableFormatPresets = []formats.Format{
+	DefaultTrafficServerLogFormat,
+	&TrafficServerLogTableFormat{
+		Name:        "common",
+		Description: "Traffic Server Netscape common format.",
+		Layout:      `%<chi> - %<caun> [%<cqtn>] "%<cqtx>" %<pssc> %<pscl>`,
+	},
+	&TrafficServerLogTableFormat{
+		Name:        "extended",
+		Description: "Traffic Server Netscape extended format.",
+		Layout:      `%<chi> - %<caun> [%<cqtn>] "%<cqtx>" %<pssc> %<pscl> %<sssc> %<sscl> %<cqcl> %<pqcl> %<cqhl> %<pshl> %<pqhl> %<sshl> %<tts>`,
+	},
+	&TrafficServerLogTableFormat{
+		Name:        "extended2",
+		Description: "Traffic Server Netscape extended-2 format.",
+		Layout:      `%<chi> - %<caun> [%<cqtn>] "%<cqtx>" %<pssc> %<pscl> %<sssc> %<sscl> %<cqcl> %<pqcl> %<cqhl> %<pshl> %<pqhl> %<sshl> %<tts> %<phr> %<cfsc> %<pfsc> %<crc>`,
+	},
+}
@@ -0,0 +1,101 @@
+package trafficserver_log
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func Test_TrafficServerLogTable_EnrichRow(t *testing.T) {
+	tests := []struct {
+		name          string
+		source        map[string]string
+		wantTimestamp time.Time
+		want          map[string]any
+	}{
+		{
+			name: "request line, cache hit and domains",
+			source: map[string]string{
+				"timestamp":         "24/Feb/2025:12:34:56 -0000",
+				"client_ip":         "192.168.1.1",
+				"client_auth_user":  "alice",
+				"request_line":      "GET http://WWW.Example.com/index.html HTTP/1.1",
+				"cache_result_code": "TCP_REFRESH_HIT",
+				"origin_host":       "origin.example.com:8080",
+				"origin_ip":         "10.0.0.8",
+			},
+			wantTimestamp: time.Date(2025, 2, 24, 12, 34, 56, 0, time.UTC),
+			want: map[string]any{
+				"request_method":        "GET",
+				"request_url":           "http://WWW.Example.com/index.html",
+				"http_version":          "HTTP/1.1",
+				"is_cache_hit":          true,
+				constants.TpIps:         []string{"192.168.1.1", "10.0.0.8"},
+				constants.TpDomains:     []string{"www.example.com", "origin.example.com"},
+				constants.TpUsernames:   []string{"alice"},
+				constants.TpSourceIP:    "192.168.1.1",
+				"cache_result_subcode":  nil,
+				"proxy_finish_status":   nil,
+				"origin_content_length": nil,
+			},
+		},
+		{
+			name: "logged method is kept, cache miss and no origin",
+			source: map[string]string{
+				"timestamp":         "2025-02-24T12:34:56.123Z",
+				"client_ip":         "2001:db8::1",
+				"request_method":    "HEAD",
+				"request_url":       "https://cdn.example.com/assets/app.js",
+				"cache_result_code": "TCP_MISS",
+				"origin_host":       "-",
+				"origin_ip":         "2001:db8::2",
+				"client_auth_user":  "-",
+			},
+			wantTimestamp: time.Date(2025, 2, 24, 12, 34, 56, 123000000, time.UTC),
+			want: map[string]any{
+				"is_cache_hit":        false,
+				constants.TpIps:       []string{"2001:db8::1", "2001:db8::2"},
+				constants.TpDomains:   []string{"cdn.example.com"},
+				constants.TpUsernames: nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &TrafficServerLogTable{}
+			if err := table.Initialize(DefaultTrafficServerLogFormat, table.GetTableDefinition()); err != nil {
+				t.Fatalf("failed to initialise table: %v", err)
+			}
+			row := &types.DynamicRow{}
+			if err := row.InitialiseFromMap(tt.source); err != nil {
+				t.Fatalf("failed to initialise row: %v", err)
+			}
+			row, err := table.EnrichRow(row, schema.SourceEnrichment{})
+			if err != nil {
+				t.Fatalf("unexpected enrichment error: %v", err)
+			}
+
+			// compare the instant, as the location depends on how the offset was logged
+			if got, ok := row.OutputColumns[constants.TpTimestamp].(time.Time); !ok || !got.Equal(tt.wantTimestamp) {
+				t.Errorf("%s: got %v, want %v", constants.TpTimestamp, row.OutputColumns[constants.TpTimestamp], tt.wantTimestamp)
+			}
+			for k, want := range tt.want {
+				got := row.OutputColumns[k]
+				if want == nil {
+					if got != nil {
+						t.Errorf("%s: got %v, want nil", k, got)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %v, want %v", k, got, want)
+				}
+			}
+		})
+	}
+}